@@ -0,0 +1,65 @@
+package fstr
+
+import "fmt"
+
+// ------------------------------------------------------------------
+// Named Args
+// ------------------------------------------------------------------
+
+// Args is a reusable set of named arguments. Pass it as argument #0 to
+// resolve named placeholders against it:
+//
+//	base := fstr.Fields("service", "api")
+//	fstr.F("{service} {msg}", base.With("msg", "up")) // "api up"
+//
+// Args values are never modified in place; With and Merge return copies.
+type Args map[string]interface{}
+
+// Fields builds Args from alternating keys and values. Keys that aren't
+// strings are converted with fmt.Sprint; a trailing key without a value
+// maps to nil.
+func Fields(kv ...interface{}) Args {
+	a := make(Args, (len(kv)+1)/2)
+	a.set(kv)
+	return a
+}
+
+// With returns a copy of a with the given key/value pairs added, replacing
+// any existing keys.
+func (a Args) With(kv ...interface{}) Args {
+	out := a.clone(len(kv) / 2)
+	out.set(kv)
+	return out
+}
+
+// Merge returns a copy of a with every entry of other added; on conflicts
+// other wins.
+func (a Args) Merge(other Args) Args {
+	out := a.clone(len(other))
+	for k, v := range other {
+		out[k] = v
+	}
+	return out
+}
+
+func (a Args) clone(extra int) Args {
+	out := make(Args, len(a)+extra)
+	for k, v := range a {
+		out[k] = v
+	}
+	return out
+}
+
+func (a Args) set(kv []interface{}) {
+	for i := 0; i < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		var val interface{}
+		if i+1 < len(kv) {
+			val = kv[i+1]
+		}
+		a[key] = val
+	}
+}
@@ -0,0 +1,39 @@
+package fstr_test
+
+import (
+	"testing"
+
+	"github.com/crazywolf132/fstr"
+)
+
+func TestArgs(t *testing.T) {
+	base := fstr.Fields("service", "api", "env", "prod")
+
+	tests := []struct {
+		name   string
+		format string
+		args   fstr.Args
+		want   string
+	}{
+		{"Fields_only", "{service}/{env}", base, "api/prod"},
+		{"With_adds_key", "{service} {msg}", base.With("msg", "up"), "api up"},
+		{"With_overrides_key", "{service}/{env}", base.With("env", "dev"), "api/dev"},
+		{"Merge_other_wins", "{service}/{env}/{region}", base.Merge(fstr.Fields("env", "stage", "region", "eu")), "api/stage/eu"},
+		{"Non_string_key_in_chain", "{0.1}", fstr.Fields(1, "one"), "one"},
+		{"Odd_count", "{a} {b}", fstr.Fields("a", 1, "b"), "1 <nil>"},
+		{"Nested_value", "{user.Name}", fstr.Fields("user", Person{Name: "Ann"}), "Ann"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	// With and Merge never modify the receiver.
+	if got := fstr.Sprintf("{env} {msg}", base); got != "prod <invalid field>" {
+		t.Errorf("base was modified: %q", got)
+	}
+}
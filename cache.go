@@ -0,0 +1,159 @@
+package fstr
+
+import (
+	"container/list"
+	"sync"
+)
+
+// ------------------------------------------------------------------
+// Format Cache
+// ------------------------------------------------------------------
+
+// maxCacheSize bounds the number of parsed formats kept by globalCache.
+const maxCacheSize = 1024
+
+// placeholderOverhead approximates the fixed memory cost of one parsed
+// placeholder, on top of the strings it holds.
+const placeholderOverhead = 64
+
+// parsedFormat is the result of parsing a format string. Once cached it is
+// shared between goroutines and must be treated as read-only.
+type parsedFormat struct {
+	segments     []string
+	placeholders []placeholder
+	plain        bool // every placeholder is a bare "{}" or "{n}"
+	copied       int  // bytes of segment text not shared with the format
+}
+
+// formatCache is a least-recently-used cache of parsed formats. It is the
+// only parse cache in the package and is what Sprintf and friends consult.
+//
+// All methods are safe for concurrent use. Two goroutines missing on the
+// same format may both parse it; the later put simply replaces the earlier
+// entry, which is harmless because parsing is deterministic.
+type formatCache struct {
+	mu       sync.Mutex
+	max      int
+	maxBytes int // 0 means no byte limit
+	bytes    int // sum of the sizes of all entries
+	entries  map[string]*list.Element
+	order    *list.List // front is most recently used
+}
+
+type cacheEntry struct {
+	format string
+	parsed *parsedFormat
+	size   int
+}
+
+// entrySize approximates the memory held by a cached format in bytes.
+// Segments that share the format's bytes are counted once, as the format.
+func entrySize(format string, parsed *parsedFormat) int {
+	size := len(format) + parsed.copied
+	for _, ph := range parsed.placeholders {
+		size += placeholderOverhead + len(ph.Raw) + len(ph.Spec)
+		for _, f := range ph.FieldChain {
+			size += len(f)
+		}
+	}
+	return size
+}
+
+var globalCache = newFormatCache(maxCacheSize)
+
+// SetCacheBytes caps the parsed-format cache at roughly n bytes, in addition
+// to its limit of 1024 formats. Least recently used formats are evicted
+// first, and a format larger than n is parsed on every use instead of being
+// cached. n <= 0 removes the byte cap, which is the default.
+func SetCacheBytes(n int) {
+	if n < 0 {
+		n = 0
+	}
+	globalCache.setMaxBytes(n)
+}
+
+func newFormatCache(max int) *formatCache {
+	return &formatCache{
+		max:     max,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *formatCache) get(format string) (*parsedFormat, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[format]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*cacheEntry).parsed, true
+}
+
+func (c *formatCache) put(format string, parsed *parsedFormat) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[format]; ok {
+		c.remove(el)
+	}
+	size := entrySize(format, parsed)
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return // would evict everything else and still not fit
+	}
+	c.entries[format] = c.order.PushFront(&cacheEntry{format: format, parsed: parsed, size: size})
+	c.bytes += size
+	c.evict()
+}
+
+// setMaxBytes changes the byte limit, evicting entries to meet it.
+func (c *formatCache) setMaxBytes(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBytes = n
+	c.evict()
+}
+
+// evict drops least recently used entries until both limits are met. The
+// caller must hold c.mu.
+func (c *formatCache) evict() {
+	for c.order.Len() > c.max || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.remove(c.order.Back())
+	}
+}
+
+// remove drops one entry. The caller must hold c.mu.
+func (c *formatCache) remove(el *list.Element) {
+	entry := c.order.Remove(el).(*cacheEntry)
+	delete(c.entries, entry.format)
+	c.bytes -= entry.size
+}
+
+func (c *formatCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *formatCache) size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes
+}
+
+// getParsedFormat returns the parsed form of format, parsing and caching it
+// on first use.
+func getParsedFormat(format string) *parsedFormat {
+	if parsed, ok := globalCache.get(format); ok {
+		return parsed
+	}
+	segments, placeholders, copied := parseFormat(format)
+	parsed := &parsedFormat{segments: segments, placeholders: placeholders, plain: true, copied: copied}
+	for _, ph := range placeholders {
+		if ph.Spec != "" || ph.Color != (colorRule{}) || ph.Choice != nil || len(ph.FieldChain) > 0 || ph.HasMissing {
+			parsed.plain = false
+		}
+	}
+	globalCache.put(format, parsed)
+	return parsed
+}
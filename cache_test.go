@@ -0,0 +1,237 @@
+package fstr
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"unsafe"
+)
+
+func TestFormatCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newFormatCache(2)
+	c.put("a", &parsedFormat{})
+	c.put("b", &parsedFormat{})
+	c.get("a") // "b" is now the least recently used
+	c.put("c", &parsedFormat{})
+
+	if _, ok := c.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if _, ok := c.get(k); !ok {
+			t.Errorf("expected %s to be cached", k)
+		}
+	}
+	if got := c.len(); got != 2 {
+		t.Errorf("len = %d, want 2", got)
+	}
+}
+
+func TestFormatCacheByteLimit(t *testing.T) {
+	const limit = 10000
+	c := newFormatCache(maxCacheSize)
+	c.setMaxBytes(limit)
+
+	for i := 0; i < 20; i++ {
+		format := strconv.Itoa(i) + strings.Repeat("x", 1000) + "{}"
+		segments, placeholders, copied := parseFormat(format)
+		c.put(format, &parsedFormat{segments: segments, placeholders: placeholders, copied: copied})
+		if got := c.size(); got > limit {
+			t.Fatalf("after %d puts size = %d, want <= %d", i+1, got, limit)
+		}
+	}
+	if got := c.len(); got == 0 || got >= 20 {
+		t.Errorf("len = %d, want some but not all entries kept", got)
+	}
+
+	last := "19" + strings.Repeat("x", 1000) + "{}"
+	if _, ok := c.get(last); !ok {
+		t.Error("expected the most recent format to be cached")
+	}
+
+	huge := strings.Repeat("y", 2*limit)
+	c.put(huge, &parsedFormat{segments: []string{huge}})
+	if _, ok := c.get(huge); ok {
+		t.Error("a format larger than the limit should not be cached")
+	}
+	if _, ok := c.get(last); !ok {
+		t.Error("an oversized format should not evict other entries")
+	}
+
+	c.setMaxBytes(1500)
+	if got := c.size(); got > 1500 || c.len() != 1 {
+		t.Errorf("after lowering the limit: size = %d, len = %d", got, c.len())
+	}
+
+	c.setMaxBytes(0)
+	c.put(huge, &parsedFormat{segments: []string{huge}})
+	if _, ok := c.get(huge); !ok {
+		t.Error("with no byte limit, large formats should be cached")
+	}
+}
+
+func TestFormatCacheSizeTracksReplacement(t *testing.T) {
+	c := newFormatCache(2)
+	p := &parsedFormat{segments: []string{"abc"}}
+	c.put("abc", p)
+	c.put("abc", p)
+	if got, want := c.size(), entrySize("abc", p); got != want {
+		t.Errorf("size = %d, want %d", got, want)
+	}
+	c.put("d", &parsedFormat{segments: []string{"d"}})
+	c.put("e", &parsedFormat{segments: []string{"e"}})
+	if got, want := c.size(), 2; got != want {
+		t.Errorf("size after eviction = %d, want %d", got, want)
+	}
+}
+
+func TestEntrySizeCountsSharedSegmentsOnce(t *testing.T) {
+	body := strings.Repeat("x", 1000)
+	tests := []struct {
+		name   string
+		format string
+		want   int
+	}{
+		{"Shared", body + "{}" + body, 2002 + placeholderOverhead + len("{}")},
+		{"Escaped", "{{" + body + "{}", 1004 + 1001 + placeholderOverhead + len("{}")},
+		{"Literal_only", body, 1000},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			segments, placeholders, copied := parseFormat(tc.format)
+			parsed := &parsedFormat{segments: segments, placeholders: placeholders, copied: copied}
+			if got := entrySize(tc.format, parsed); got != tc.want {
+				t.Errorf("entrySize = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSprintfUsesCache(t *testing.T) {
+	const format = "cache {} test {Name}"
+	Sprintf(format, "x")
+	first, ok := globalCache.get(format)
+	if !ok {
+		t.Fatal("format was not cached")
+	}
+	Sprintf(format, "y")
+	second, _ := globalCache.get(format)
+	if first != second {
+		t.Error("second call re-parsed a cached format")
+	}
+}
+
+// Run with -race: formats the same and different formats concurrently.
+func TestCacheConcurrentSprintf(t *testing.T) {
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if got := Sprintf("shared {} {}", g, i); got != "shared "+strconv.Itoa(g)+" "+strconv.Itoa(i) {
+					t.Errorf("shared format: got %q", got)
+					return
+				}
+				unique := "unique " + strconv.Itoa(g) + "-" + strconv.Itoa(i%50) + " {}"
+				if got, want := Sprintf(unique, i), unique[:len(unique)-2]+strconv.Itoa(i); got != want {
+					t.Errorf("unique format: got %q, want %q", got, want)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func TestCachedFormatsNeverMix(t *testing.T) {
+	// Formats sharing long prefixes, and ones whose literal text appears
+	// inside each other, must each keep their own parse.
+	body := strings.Repeat("lorem ipsum ", 500)
+	formats := []string{
+		body + "{}",
+		body + "{} ",
+		body + "{{}}",
+		"{}" + body,
+		"ab{}ab{}ab",
+		"{{ab}}{}ab",
+		"ab}}{}{{ab",
+	}
+	want := []string{
+		body + "1",
+		body + "1 ",
+		body + "{}",
+		"1" + body,
+		"ab1ab2ab",
+		"{ab}1ab",
+		"ab}1{ab",
+	}
+	for round := 0; round < 2; round++ { // parse, then hit the cache
+		for i, format := range formats {
+			if got := Sprintf(format, 1, 2); got != want[i] {
+				t.Errorf("round %d, format %d: got %.40q..., want %.40q...", round, i, got, want[i])
+			}
+		}
+	}
+}
+
+func TestSegmentsShareFormatBytes(t *testing.T) {
+	// stringData returns the address of s's bytes.
+	stringData := func(s string) uintptr { return *(*uintptr)(unsafe.Pointer(&s)) }
+	within := func(seg, format string) bool {
+		lo, p := stringData(format), stringData(seg)
+		return p >= lo && p < lo+uintptr(len(format))
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		want   []string
+		shared []bool
+	}{
+		{"Plain", "head {} mid {} tail", []string{"head ", " mid ", " tail"}, []bool{true, true, true}},
+		{"Escaped", "head {} mid {} tail {{x}}", []string{"head ", " mid ", " tail {x}"}, []bool{true, true, false}},
+		{"After_escapes", "{{a}} {} a}", []string{"{a} ", " a}"}, []bool{false, true}},
+		{"Raw_region", "```{x}``` {} y", []string{"{x} ", " y"}, []bool{false, true}},
+		{"Multibyte", "héllo {} wörld", []string{"héllo ", " wörld"}, []bool{true, true}},
+		{"Invalid_utf8", "a\xff {} b", []string{"a\uFFFD ", " b"}, []bool{false, true}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			segments, _, _ := parseFormat(tc.format)
+			if !reflect.DeepEqual(segments, tc.want) {
+				t.Fatalf("segments = %q, want %q", segments, tc.want)
+			}
+			for i, seg := range segments {
+				if got := within(seg, tc.format); got != tc.shared[i] {
+					t.Errorf("segment %d (%q) shares format's bytes: %v, want %v", i, seg, got, tc.shared[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCacheKeysNeverCollide(t *testing.T) {
+	// Thousands of same-length keys that differ in a single byte must share
+	// map buckets; each lookup must still return its own parse.
+	const n = 4096
+	c := newFormatCache(n)
+	body := strings.Repeat("x", 1000)
+	key := func(i int) string { return body + strconv.Itoa(i+n) + "{}" }
+	parsed := make([]*parsedFormat, n)
+	for i := range parsed {
+		parsed[i] = &parsedFormat{segments: []string{strconv.Itoa(i)}}
+		c.put(key(i), parsed[i])
+	}
+	for i := range parsed {
+		got, ok := c.get(key(i))
+		if !ok || got != parsed[i] {
+			t.Fatalf("key %d: got %v (cached %v), want its own parse", i, got, ok)
+		}
+	}
+	if _, ok := c.get(body + strconv.Itoa(2*n) + "{}"); ok {
+		t.Error("an uncached key of the same length hit the cache")
+	}
+}
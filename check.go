@@ -0,0 +1,299 @@
+package fstr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ------------------------------------------------------------------
+// Argument Checks
+// ------------------------------------------------------------------
+
+// CheckArgs reports arguments that Sprintf(format, args...) would ignore:
+// positional arguments no placeholder reads, and keys of an Args argument
+// no named placeholder refers to. It returns nil when every argument is
+// used. Write "{_}" to consume an argument on purpose without printing it.
+//
+//	fstr.CheckArgs("{} and {}", a, b, c) // error: argument 2 ("c") is unused
+func CheckArgs(format string, args ...interface{}) error {
+	args, _ = splitOptions(args)
+	used := make([]bool, len(args))
+	usedKeys := make(map[int]map[string]bool)
+	use := func(idx int, chain []string) {
+		if idx >= len(args) {
+			return
+		}
+		used[idx] = true
+		if len(chain) > 0 {
+			if usedKeys[idx] == nil {
+				usedKeys[idx] = make(map[string]bool)
+			}
+			usedKeys[idx][chain[0]] = true
+		}
+	}
+
+	autoIndex := 0
+	for _, ph := range getParsedFormat(format).placeholders {
+		switch {
+		case ph.Skip || (ph.PositionalIndex == nil && len(ph.FieldChain) == 0):
+			use(autoIndex, nil)
+			autoIndex++
+		case ph.PositionalIndex == nil:
+			use(0, ph.FieldChain)
+		case *ph.PositionalIndex < len(args):
+			use(*ph.PositionalIndex, ph.FieldChain)
+		default:
+			// Out of range: looked up as a key of a map in argument #0.
+			use(0, []string{strconv.Itoa(*ph.PositionalIndex)})
+		}
+	}
+
+	var problems []string
+	for i, arg := range args {
+		if !used[i] {
+			problems = append(problems, fmt.Sprintf("argument %d (%#v) is unused", i, arg))
+			continue
+		}
+		named, ok := arg.(Args)
+		if !ok || usedKeys[i] == nil {
+			continue // Args passed to "{}" as a whole uses all of it
+		}
+		var unused []string
+		for key := range named {
+			if !usedKeys[i][key] {
+				unused = append(unused, key)
+			}
+		}
+		sort.Strings(unused)
+		for _, key := range unused {
+			problems = append(problems, fmt.Sprintf("named argument %q is unused", key))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(problems, "; "))
+}
+
+// RequiredArgs returns how many positional arguments format reads: one per
+// "{}" or "{_}", and enough for the highest "{n}" or "{n.Field}". Named
+// placeholders are not counted; see NamedFields.
+func RequiredArgs(format string) int {
+	required, autoIndex := 0, 0
+	for _, ph := range getParsedFormat(format).placeholders {
+		switch {
+		case ph.Skip || (ph.PositionalIndex == nil && len(ph.FieldChain) == 0):
+			autoIndex++
+			if autoIndex > required {
+				required = autoIndex
+			}
+		case ph.PositionalIndex != nil && *ph.PositionalIndex >= required:
+			required = *ph.PositionalIndex + 1
+		}
+	}
+	return required
+}
+
+// NamedFields returns the field paths of the named placeholders in format,
+// such as "Name" or "Address.City", in order of first use and without
+// repeats. Dots, colons and other delimiters that are part of a key are
+// escaped with a backslash, so each path can be used in a format as is.
+//
+//	fstr.NamedFields("{user.name} ({user.age}) {0}") // [user.name user.age]
+func NamedFields(format string) []string {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, ph := range getParsedFormat(format).placeholders {
+		if ph.PositionalIndex != nil || len(ph.FieldChain) == 0 {
+			continue
+		}
+		escaped := make([]string, len(ph.FieldChain))
+		for i, f := range ph.FieldChain {
+			escaped[i] = nameEscaper.Replace(f)
+		}
+		path := strings.Join(escaped, ".")
+		if !seen[path] {
+			seen[path] = true
+			fields = append(fields, path)
+		}
+	}
+	return fields
+}
+
+// nameEscaper escapes the characters that would end a name in a placeholder.
+var nameEscaper = strings.NewReplacer(`\`, `\\`, ".", `\.`, ":", `\:`, "|", `\|`, "!", `\!`, "?", `\?`)
+
+// ------------------------------------------------------------------
+// Format Checks
+// ------------------------------------------------------------------
+
+// Validate reports the first problem in format that Sprintf would otherwise
+// work around: an unmatched '{' or '}', a malformed spec, an unknown verb or
+// an unknown color. The error is a *FormatError. While any formatter is
+// registered, Validate accepts every verb name, since it has no values to
+// tell which verbs a formatter will be given; SprintfChecked still checks.
+func Validate(format string) error {
+	r := []rune(format)
+	for i := 0; i < len(r); i++ {
+		switch r[i] {
+		case '`':
+			if end := findRawEnd(r, i); end != -1 {
+				i = end + len(rawDelim) - 1
+			}
+		case '{':
+			if i+1 < len(r) && r[i+1] == '{' {
+				i++
+				continue
+			}
+			closing := findClosingBrace(r, i+1)
+			if closing == -1 {
+				return &FormatError{Reason: fmt.Sprintf("unmatched '{' at offset %d", len(string(r[:i])))}
+			}
+			i = closing
+		case '}':
+			if i+1 < len(r) && r[i+1] == '}' {
+				i++
+				continue
+			}
+			return &FormatError{Reason: fmt.Sprintf("unmatched '}' at offset %d", len(string(r[:i])))}
+		}
+	}
+	for _, ph := range getParsedFormat(format).placeholders {
+		if reason := placeholderProblem(ph); reason != "" {
+			return &FormatError{Placeholder: ph.Raw, Reason: reason}
+		}
+	}
+	return nil
+}
+
+// placeholderProblem returns what Validate reports for ph, or "".
+func placeholderProblem(ph placeholder) string {
+	if ph.Spec != "" && !ph.Format.goDirective() && ph.Format.valueTemplate() == "" {
+		if _, err := ParseSpecifier(ph.Spec); err != nil {
+			return err.Error()
+		}
+	}
+	if !ph.Format.knownVerb() && atomic.LoadInt32(&formattersRegistered) == 0 {
+		return fmt.Sprintf("unknown verb %q", ph.Format.Verb)
+	}
+	if ph.Color != (colorRule{}) || ph.Choice != nil {
+		return ""
+	}
+	// A "|name" suffix that splitColor didn't take names no known style.
+	inside := ph.Raw[1 : len(ph.Raw)-1]
+	if bar := lastIndexUnescaped(inside, '|'); bar >= 0 {
+		style, _, _ := strings.Cut(inside[bar+1:], "?")
+		if isStyleName(style) {
+			return fmt.Sprintf("unknown color %q", style)
+		}
+	}
+	return ""
+}
+
+// isStyleName reports whether s looks like a style, such as "red" or
+// "bold+red", rather than a fill character's spec.
+func isStyleName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '+' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// SprintfChecked is Sprintf for formats that may be wrong, such as ones
+// written by users. It runs Validate and CheckArgs, then formats, and fails
+// on a placeholder with no argument or field to read. On failure it returns
+// "" and a *FormatError. An unknown verb fails unless the value it applies
+// to has a registered formatter. With SetStrictTypes, type mismatches fail too.
+func SprintfChecked(format string, args ...interface{}) (string, error) {
+	if err := Validate(format); err != nil {
+		return "", err
+	}
+	if err := CheckArgs(format, args...); err != nil {
+		return "", &FormatError{Reason: err.Error()}
+	}
+	s, _, err := render(format, args, callOptions{requireValues: true})
+	if err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// ------------------------------------------------------------------
+// Type Checks
+// ------------------------------------------------------------------
+
+// FormatError describes a format or placeholder that couldn't be formatted,
+// such as a "{:f}" given a string while strict types are on (see
+// SetStrictTypes). Functions that return an error, like Fappendf and
+// SprintfTo, report the first one as a *FormatError.
+type FormatError struct {
+	Placeholder string // the placeholder, braces included, e.g. "{:f}"; "" for the whole format
+	Reason      string // what was wrong, e.g. "verb f needs a float, got string"
+}
+
+func (e *FormatError) Error() string {
+	if e.Placeholder == "" {
+		return "fstr: " + e.Reason
+	}
+	return "fstr: " + e.Placeholder + ": " + e.Reason
+}
+
+// typeMismatch returns why fs can't format val when strict types are on, or
+// "" when it can. Only printf-style verbs with a fixed type are checked;
+// registered formatters and fmt.Formatter values format themselves.
+func typeMismatch(val interface{}, fs FormatSpecifier) string {
+	if _, missing := val.(missingValue); missing || len(fs.Verb) != 1 {
+		return ""
+	}
+	if _, ok := val.(fmt.Formatter); ok {
+		return ""
+	}
+	if _, _, ok := formatterFor(val); ok {
+		return ""
+	}
+
+	rv := reflect.ValueOf(val)
+	var want string
+	var ok bool
+	switch {
+	case strings.Contains("eEfFgG", fs.Verb):
+		want, ok = "a float", isFloatKind(rv.Kind())
+	case strings.Contains("dboOcU", fs.Verb):
+		want, ok = "an integer", isIntegerKind(rv.Kind())
+	case fs.Verb == "x" || fs.Verb == "X":
+		// fmt also hex-encodes byte slices
+		want = "an integer or []byte"
+		ok = isIntegerKind(rv.Kind()) ||
+			(rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8)
+	case fs.Verb == "t":
+		want, ok = "a bool", rv.Kind() == reflect.Bool
+	default:
+		return ""
+	}
+	if ok {
+		return ""
+	}
+	got := "nil"
+	if val != nil {
+		got = rv.Type().String()
+	}
+	return "verb " + fs.Verb + " needs " + want + ", got " + got
+}
+
+func isIntegerKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Uintptr
+}
+
+func isFloatKind(k reflect.Kind) bool {
+	return k >= reflect.Float32 && k <= reflect.Complex128
+}
@@ -0,0 +1,211 @@
+package fstr_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/crazywolf132/fstr"
+)
+
+func TestSkipPlaceholder(t *testing.T) {
+	got, resolved := fstr.SprintfN("{}{_} {}", "a", "hidden", "b")
+	if got != "a b" {
+		t.Errorf("got %q, want %q", got, "a b")
+	}
+	if resolved != 2 {
+		t.Errorf("resolved = %d, want 2", resolved)
+	}
+}
+
+func TestCheckArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"All_used", "{} and {}", []interface{}{1, 2}, ""},
+		{"Extra_positional", "{} and {}", []interface{}{1, 2, "c"}, `argument 2 ("c") is unused`},
+		{"Skipped_counts_as_used", "{} {_} {}", []interface{}{1, 2, 3}, ""},
+		{"Explicit_index", "{1}", []interface{}{1, 2}, "argument 0 (1) is unused"},
+		{"Repeated_index", "{0} {0}", []interface{}{1}, ""},
+		{"Field_uses_first", "{Name}", []interface{}{struct{ Name string }{"x"}, 2}, "argument 1 (2) is unused"},
+		{"Unused_named", "{a}", []interface{}{fstr.Args{"a": 1, "b": 2, "c": 3}},
+			`named argument "b" is unused; named argument "c" is unused`},
+		{"Named_chain", "{a.X}", []interface{}{fstr.Args{"a": struct{ X int }{1}}}, ""},
+		{"Whole_args", "{}", []interface{}{fstr.Args{"a": 1}}, ""},
+		{"No_placeholders", "plain", []interface{}{1}, "argument 0 (1) is unused"},
+		{"No_args", "{}", nil, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := fstr.CheckArgs(tc.format, tc.args...)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRequiredArgs(t *testing.T) {
+	tests := []struct {
+		format string
+		want   int
+	}{
+		{"plain", 0},
+		{"{} and {}", 2},
+		{"{} {_} {}", 3},
+		{"{2}", 3},
+		{"{0} {} {}", 2},
+		{"{1.Name} {}", 2},
+		{"{Name} {Age}", 0},
+		{"{Name} {}", 1},
+		{"{{}} {:>5}", 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			if got := fstr.RequiredArgs(tc.format); got != tc.want {
+				t.Errorf("RequiredArgs(%q) = %d, want %d", tc.format, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNamedFields(t *testing.T) {
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"{} {0} {1.Name}", nil},
+		{"{Name} is {Age:>3}", []string{"Name", "Age"}},
+		{"{user.name} ({user.age}) {user.name}", []string{"user.name", "user.age"}},
+		{`{config\.timeout|red} {}`, []string{`config\.timeout`}},
+		{`{content\:type} {a\|b.c}`, []string{`content\:type`, `a\|b.c`}},
+		{"{a!n/a} {b?true?(yes):(no)} {{c}}", []string{"a", "b"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			if got := fstr.NamedFields(tc.format); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("NamedFields(%q) = %q, want %q", tc.format, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{"Valid", "{} {name:>8.2f|red} {{literal}} {:yaml}", ""},
+		{"Raw_region", "```{```", ""},
+		{"Fill_bar", "{:|>8}", ""},
+		{"Conditional", "{ok?true?(OK|green):(FAIL|red)}", ""},
+		{"Unclosed", "a {b c", "fstr: unmatched '{' at offset 2"},
+		{"Stray_close", "é} {}", "fstr: unmatched '}' at offset 2"},
+		{"Unknown_verb", "{:bogus}", `fstr: {:bogus}: unknown verb "bogus"`},
+		{"Bad_precision", "{:>5.}", `fstr: {:>5.}: missing precision after '.' in spec ">5."`},
+		{"Unknown_color", "{name|reed}", `fstr: {name|reed}: unknown color "reed"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := fstr.Validate(tc.format)
+			if tc.want == "" {
+				if err != nil {
+					t.Errorf("Validate(%q) = %v, want nil", tc.format, err)
+				}
+				return
+			}
+			var fe *fstr.FormatError
+			if !errors.As(err, &fe) || err.Error() != tc.want {
+				t.Errorf("Validate(%q) = %v, want *FormatError %q", tc.format, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestSprintfChecked(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+		err    string
+	}{
+		{"Ok", "{} has {Age} years", []interface{}{Person{Name: "Ann", Age: 41}}, "{Ann  41 <nil>} has 41 years", ""},
+		{"Bad_format", "{} {", []interface{}{1}, "", "fstr: unmatched '{' at offset 3"},
+		{"Missing_arg", "{} and {}", []interface{}{1}, "", "fstr: {}: no argument"},
+		{"Missing_field", "{Nmae}", []interface{}{Person{Name: "Ann"}}, "", "fstr: {Nmae}: no such field or key"},
+		{"Unused_arg", "{}", []interface{}{1, 2}, "", "fstr: argument 1 (2) is unused"},
+		{"Marker_is_not_missing", "{name!-}", []interface{}{fstr.Args{}}, "-", ""},
+		{"Skip", "{_}{}", []interface{}{1, 2}, "2", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := fstr.SprintfChecked(tc.format, tc.args...)
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			if tc.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var fe *fstr.FormatError
+			if !errors.As(err, &fe) || err.Error() != tc.err {
+				t.Errorf("error = %v, want *FormatError %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestCheckedFormatterVerbs(t *testing.T) {
+	type Cents int64
+	fstr.RegisterFormatter(reflect.TypeOf(Cents(0)), func(v interface{}, spec fstr.FormatSpecifier) string {
+		if spec.Verb == "currency" {
+			return fstr.Sprintf("{:.2f} €", float64(v.(Cents))/100)
+		}
+		return fstr.Sprintf("{}", int64(v.(Cents)))
+	})
+	t.Cleanup(func() { fstr.RegisterFormatter(reflect.TypeOf(Cents(0)), nil) })
+
+	if err := fstr.Validate("{:currency}"); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		arg  interface{}
+		want string
+		err  string
+	}{
+		{"Formatter_verb", Cents(1250), "12.50 €", ""},
+		{"No_formatter", 1250, "", `fstr: {:currency}: unknown verb "currency"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := fstr.SprintfChecked("{:currency}", tc.arg)
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			if tc.err == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			var fe *fstr.FormatError
+			if !errors.As(err, &fe) || err.Error() != tc.err {
+				t.Errorf("error = %v, want *FormatError %q", err, tc.err)
+			}
+		})
+	}
+}
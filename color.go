@@ -0,0 +1,120 @@
+package fstr
+
+import "strings"
+
+// ------------------------------------------------------------------
+// Colors
+// ------------------------------------------------------------------
+
+// colorCodes maps the style names accepted after '|' in a placeholder to
+// their ANSI SGR parameters. Several styles combine with '+', as in
+// "{:x|bold+red}".
+var colorCodes = map[string]string{
+	"bold":      "1",
+	"dim":       "2",
+	"italic":    "3",
+	"underline": "4",
+	"black":     "30",
+	"red":       "31",
+	"green":     "32",
+	"yellow":    "33",
+	"blue":      "34",
+	"magenta":   "35",
+	"cyan":      "36",
+	"white":     "37",
+	"gray":      "90",
+}
+
+const colorReset = "\x1b[0m"
+
+// colorSequence returns the escape sequence that starts the given style, or
+// false if any part of it isn't a known style name.
+func colorSequence(style string) (string, bool) {
+	parts := strings.Split(style, "+")
+	codes := make([]string, len(parts))
+	for i, part := range parts {
+		code, ok := colorCodes[part]
+		if !ok {
+			return "", false
+		}
+		codes[i] = code
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m", true
+}
+
+// colorize wraps s in the escape codes for style. An empty or unknown style
+// leaves s unchanged.
+func colorize(s, style string) string {
+	seq, ok := colorSequence(style)
+	if !ok {
+		return s
+	}
+	return seq + s + colorReset
+}
+
+// colorRule is the parsed "|style" suffix of a placeholder. With a
+// condition, as in "|red?neg:green", style applies when the condition holds
+// and elseStyle (possibly "") otherwise.
+type colorRule struct {
+	style     string
+	cond      string
+	elseStyle string
+}
+
+// pick returns the style to use for val.
+func (c colorRule) pick(val interface{}) string {
+	if c.cond == "" || conditionHolds(val, c.cond) {
+		return c.style
+	}
+	return c.elseStyle
+}
+
+// parseColorRule parses "style", "style?cond" or "style?cond:style".
+func parseColorRule(text string) (colorRule, bool) {
+	style, rest, hasCond := strings.Cut(text, "?")
+	if _, ok := colorSequence(style); !ok {
+		return colorRule{}, false
+	}
+	if !hasCond {
+		return colorRule{style: style}, true
+	}
+	cond, elseStyle, hasElse := strings.Cut(rest, ":")
+	if !validCondition(cond) {
+		return colorRule{}, false
+	}
+	if _, ok := colorSequence(elseStyle); hasElse && !ok {
+		return colorRule{}, false
+	}
+	return colorRule{style: style, cond: cond, elseStyle: elseStyle}, true
+}
+
+// splitColor splits a trailing "|style" or "|style?cond:style" off the text
+// inside a placeholder. The split only happens when the suffix is made of
+// known style names and conditions, so a '|' used as a fill character, as
+// in "{:|>8}", stays part of the spec, and "\|" never splits.
+func splitColor(inside string) (rest string, color colorRule) {
+	i := lastIndexUnescaped(inside, '|')
+	if i < 0 {
+		return inside, colorRule{}
+	}
+	color, ok := parseColorRule(inside[i+1:])
+	if !ok {
+		return inside, colorRule{}
+	}
+	return inside[:i], color
+}
+
+// stripColor removes the escape sequences written by colorize.
+func stripColor(s string) string {
+	for {
+		start := strings.Index(s, "\x1b[")
+		if start < 0 {
+			return s
+		}
+		end := strings.IndexByte(s[start:], 'm')
+		if end < 0 {
+			return s
+		}
+		s = s[:start] + s[start+end+1:]
+	}
+}
@@ -0,0 +1,117 @@
+package fstr_test
+
+import (
+	"testing"
+
+	"github.com/crazywolf132/fstr"
+)
+
+func TestColors(t *testing.T) {
+	const (
+		red   = "\x1b[31m"
+		reset = "\x1b[0m"
+	)
+	person := Person{Name: "Ivy", Age: 255}
+
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Positional_field_spec_and_color", "{0.Age:x|red}", []interface{}{person}, red + "ff" + reset},
+		{"Auto_placeholder", "{|red}", []interface{}{1}, red + "1" + reset},
+		{"Spec_only", "{:>4|red}", []interface{}{7}, red + "   7" + reset},
+		{"Named_field", "{Name|green}", []interface{}{person}, "\x1b[32mIvy" + reset},
+		{"Combined_styles", "{:x|bold+red}", []interface{}{255}, "\x1b[1;31mff" + reset},
+		{"Pipe_fill_is_not_a_color", "{:|>4}", []interface{}{7}, "|||7"},
+		{"Pipe_fill_with_color", "{:|>4|red}", []interface{}{7}, red + "|||7" + reset},
+		{"Unknown_color_stays_in_spec", "{:x|mauve}", []interface{}{255}, "255"},
+		{"Column_ignores_escape_codes", "{|red}{:.>@6}", []interface{}{"ab", "c"}, red + "ab" + reset + "...c"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConditionalColors(t *testing.T) {
+	const (
+		red   = "\x1b[31m"
+		green = "\x1b[32m"
+		reset = "\x1b[0m"
+	)
+
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Negative_is_red", "{amount|red?neg:green}", []interface{}{map[string]int{"amount": -5}}, red + "-5" + reset},
+		{"Positive_is_green", "{amount|red?neg:green}", []interface{}{map[string]int{"amount": 5}}, green + "5" + reset},
+		{"No_else_branch", "{|red?neg}", []interface{}{5}, "5"},
+		{"With_spec", "{:>5.1f|red?neg:green}", []interface{}{-2.25}, red + " -2.2" + reset},
+		{"Threshold", "{|red?>100:green}", []interface{}{150}, red + "150" + reset},
+		{"Threshold_not_met", "{|red?>=100.5:green}", []interface{}{100.5}, red + "100.5" + reset},
+		{"Zero", "{|gray?zero}", []interface{}{0}, "\x1b[90m0" + reset},
+		{"Empty_string", "{|red?empty:green}", []interface{}{""}, red + reset},
+		{"Empty_missing_value", "{} {|red?empty}", []interface{}{1}, "1 " + red + "<no value>" + reset},
+		{"Boolean", "{|green?true:red}", []interface{}{false}, red + "false" + reset},
+		{"Non_number_is_not_negative", "{|red?neg:green}", []interface{}{"-1"}, green + "-1" + reset},
+		{"Unknown_condition_stays_in_spec", "{:x|red?odd}", []interface{}{255}, "255"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConditionalText(t *testing.T) {
+	const (
+		red   = "\x1b[31m"
+		green = "\x1b[32m"
+		bold  = "\x1b[1m"
+		reset = "\x1b[0m"
+	)
+	status := fstr.Args{"ok": true, "failed": false, "errors": 3, "name": ""}
+
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Then_branch_color", "{ok?true?(OK|green):(FAIL|red)}", []interface{}{status}, green + "OK" + reset},
+		{"Else_branch_color", "{failed?true?(OK|green):(FAIL|red)}", []interface{}{status}, red + "FAIL" + reset},
+		{"Plain_branches", "{ok?true?yes:no}", []interface{}{status}, "yes"},
+		{"Only_one_branch_colored", "{failed?true?(OK|green):FAIL}", []interface{}{status}, "FAIL"},
+		{"No_else_branch", "[{failed?true?done}]", []interface{}{status}, "[]"},
+		{"Comparison", "{errors?>0?(check logs|red):all good}", []interface{}{status}, red + "check logs" + reset},
+		{"Empty_condition", "{name?empty?anonymous:named}", []interface{}{status}, "anonymous"},
+		{"Missing_value_is_empty", "{nope?empty?(unset|bold)}", []interface{}{status}, bold + "unset" + reset},
+		{"Auto_argument", "{?neg?minus:plus} {}", []interface{}{-1, 2}, "minus 2"},
+		{"Positional_argument", "{1?zero?none:some}", []interface{}{1, 0}, "none"},
+		{"Placeholder_color_for_plain_branch", "{ok?true?OK:FAIL|bold}", []interface{}{status}, bold + "OK" + reset},
+		{"Branch_color_wins", "{ok?true?(OK|green):FAIL|bold}", []interface{}{status}, green + "OK" + reset},
+		{"Escaped_colon", `{ok?true?at 12\:00:never}`, []interface{}{status}, "at 12:00"},
+		{"Colon_in_parentheses", "{ok?true?(a:b|red):c}", []interface{}{status}, red + "a:b" + reset},
+		{"Invalid_condition_is_a_field", "{ok?maybe?a:b}", []interface{}{status}, "<invalid field>"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package fstr
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ------------------------------------------------------------------
+// Conditions
+// ------------------------------------------------------------------
+
+// A condition is a predicate on a placeholder's value, such as "neg" or
+// ">100". The named conditions are:
+//
+//	neg, pos, zero  the sign of a number
+//	true, false     a boolean's value
+//	empty           nil, a missing value, or a zero-length string,
+//	                slice, array, map or channel
+//
+// and a comparison operator (>, >=, <, <=, ==, !=) followed by a number
+// compares a numeric value against it. Values of the wrong kind never
+// satisfy a condition, except that missing values are empty.
+
+// validCondition reports whether cond is a condition conditionHolds knows.
+func validCondition(cond string) bool {
+	switch cond {
+	case "neg", "pos", "zero", "true", "false", "empty":
+		return true
+	}
+	_, _, ok := parseComparison(cond)
+	return ok
+}
+
+// conditionHolds reports whether val satisfies cond.
+func conditionHolds(val interface{}, cond string) bool {
+	if _, missing := val.(missingValue); missing {
+		return cond == "empty"
+	}
+	rv := reflect.ValueOf(val)
+
+	switch cond {
+	case "empty":
+		return isEmpty(rv)
+	case "true", "false":
+		return rv.Kind() == reflect.Bool && rv.Bool() == (cond == "true")
+	}
+
+	n, ok := numberOf(rv)
+	if !ok {
+		return false
+	}
+	switch cond {
+	case "neg":
+		return n < 0
+	case "pos":
+		return n > 0
+	case "zero":
+		return n == 0
+	}
+
+	op, limit, ok := parseComparison(cond)
+	if !ok {
+		return false
+	}
+	switch op {
+	case ">":
+		return n > limit
+	case ">=":
+		return n >= limit
+	case "<":
+		return n < limit
+	case "<=":
+		return n <= limit
+	case "==":
+		return n == limit
+	default: // "!="
+		return n != limit
+	}
+}
+
+// parseComparison splits a condition such as ">=10" into its operator and
+// number.
+func parseComparison(cond string) (op string, limit float64, ok bool) {
+	for _, candidate := range []string{">=", "<=", "==", "!=", ">", "<"} {
+		if strings.HasPrefix(cond, candidate) {
+			limit, err := strconv.ParseFloat(cond[len(candidate):], 64)
+			return candidate, limit, err == nil
+		}
+	}
+	return "", 0, false
+}
+
+func isEmpty(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// numberOf returns the value of any integer or float kind as a float64.
+func numberOf(rv reflect.Value) (float64, bool) {
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// ------------------------------------------------------------------
+// Conditional Text
+// ------------------------------------------------------------------
+
+// textChoice is the parsed "?cond?then:else" part of a placeholder such as
+// "{ok?true?(OK|green):(FAIL|red)}". The placeholder prints one of the two
+// branches instead of its value.
+type textChoice struct {
+	cond      string
+	then, els string
+	thenStyle colorRule
+	elseStyle colorRule
+}
+
+// pick returns the branch text for val and its color, which is color
+// unless the branch has its own.
+func (c *textChoice) pick(val interface{}, color colorRule) (string, colorRule) {
+	text, own := c.els, c.elseStyle
+	if conditionHolds(val, c.cond) {
+		text, own = c.then, c.thenStyle
+	}
+	if own != (colorRule{}) {
+		color = own
+	}
+	return text, color
+}
+
+// splitChoice splits "name?cond?then:else" into the argument part and the
+// choice. It reports false, leaving inside to be parsed as usual, unless
+// the text has two unescaped '?' around a valid condition. The else branch
+// is optional. A branch in parentheses may end with a color, as in
+// "(OK|green)", and a backslash escapes ':', '?' or a parenthesis.
+func splitChoice(inside string) (string, *textChoice, bool) {
+	q := indexUnescaped(inside, '?')
+	if q < 0 {
+		return inside, nil, false
+	}
+	rest := inside[q+1:]
+	q2 := indexUnescaped(rest, '?')
+	if q2 < 0 || !validCondition(rest[:q2]) {
+		return inside, nil, false
+	}
+
+	choice := &textChoice{cond: rest[:q2]}
+	branches := rest[q2+1:]
+	then, els := branches, ""
+	if colon := indexBranchEnd(branches); colon >= 0 {
+		then, els = branches[:colon], branches[colon+1:]
+	}
+	choice.then, choice.thenStyle = parseBranch(then)
+	choice.els, choice.elseStyle = parseBranch(els)
+	return inside[:q], choice, true
+}
+
+// indexBranchEnd returns the index of the ':' separating two branches,
+// skipping escaped characters and anything inside parentheses.
+func indexBranchEnd(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ':':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseBranch parses "text" or "(text|color)" into unescaped text and its
+// color, if any.
+func parseBranch(s string) (string, colorRule) {
+	var color colorRule
+	if len(s) >= 2 && s[0] == '(' && s[len(s)-1] == ')' {
+		s, color = splitColor(s[1 : len(s)-1])
+	}
+	return unescape(s), color
+}
+
+// unescape removes the backslash from each backslash-escaped character.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
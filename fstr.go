@@ -1,24 +1,131 @@
 package fstr
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"os"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"unicode/utf8"
 )
 
 // Sprintf formats according to a format specifier (with Rust-like placeholders).
 // See the doc comment for full details on placeholders, escaping, etc.
 func Sprintf(format string, args ...interface{}) string {
-	segments, placeholders := parseFormat(format)
+	s, _, _ := render(format, args, callOptions{})
+	return s
+}
+
+// SprintfEach formats format once per item, with the item as its only
+// argument, and joins the results with sep. Named placeholders read the
+// item's fields or keys, and "{}" prints the item itself.
+//
+//	fstr.SprintfEach("{Name}: {Age}", people, "\n")
+func SprintfEach(format string, items []interface{}, sep string) string {
+	var sb strings.Builder
+	for i, item := range items {
+		if i > 0 {
+			sb.WriteString(sep)
+		}
+		s, _, _ := render(format, []interface{}{item}, callOptions{})
+		sb.WriteString(s)
+	}
+	return sb.String()
+}
+
+// SprintfN is like Sprintf but also reports how many placeholders resolved
+// to a real value rather than "<no value>" or "<invalid field>".
+func SprintfN(format string, args ...interface{}) (string, int) {
+	s, resolved, _ := render(format, args, callOptions{})
+	return s, resolved
+}
+
+// SprintfWith is like Sprintf, but named placeholders such as "{Name}" or
+// "{Address.City}" are looked up on data, leaving args for the positional
+// placeholders "{}" and "{n}".
+//
+//	fstr.SprintfWith(user, "{Name} logged in {} times", count)
+func SprintfWith(data interface{}, format string, args ...interface{}) string {
+	s, _, _ := render(format, args, callOptions{data: data, hasData: true})
+	return s
+}
+
+// render formats args into format and counts the resolved placeholders,
+// reporting the first *FormatError. Options among args take precedence over
+// those in opts.
+func render(format string, args []interface{}, opts callOptions) (string, int, error) {
+	args, given := splitOptions(args)
+	if given.locale != nil {
+		opts.locale = given.locale
+	}
+	parsed := getParsedFormat(format)
+	if len(parsed.placeholders) == 0 {
+		return parsed.segments[0], 0, nil // literal only, escapes already applied
+	}
+	if parsed.plain && opts.locale == nil && !opts.requireValues {
+		s, resolved := renderPlain(parsed, args)
+		return s, resolved, nil
+	}
+	var sb strings.Builder
+	resolved, err := renderTo(parsed, args, opts, func(piece string) error {
+		sb.WriteString(piece)
+		return nil
+	})
+	return sb.String(), resolved, err
+}
+
+// renderPlain is render for formats whose placeholders are all bare "{}" or
+// "{n}", which need no spec, color or column handling.
+func renderPlain(parsed *parsedFormat, args []interface{}) (string, int) {
+	var sb strings.Builder
+	resolved, autoIndex := 0, 0
+	for i, ph := range parsed.placeholders {
+		var val interface{}
+		if ph.PositionalIndex == nil {
+			val = getArgOrNoValue(autoIndex, args)
+			autoIndex++
+		} else {
+			val = getPositionalArg(*ph.PositionalIndex, args)
+		}
+		if _, missing := val.(missingValue); !missing {
+			resolved++
+		}
+		sb.WriteString(parsed.segments[i])
+		sb.WriteString(formatValue(val, FormatSpecifier{}))
+	}
+	sb.WriteString(parsed.segments[len(parsed.placeholders)])
+	return sb.String(), resolved
+}
+
+// renderTo formats args into a parsed format, passing the output to emit
+// one literal segment or placeholder at a time. It stops at the first error
+// from emit and returns it along with the number of resolved placeholders;
+// otherwise it returns the first *FormatError, after writing everything.
+// Numbers follow opts.locale when it isn't nil, and named placeholders read
+// opts.data instead of argument #0 when it is set.
+func renderTo(parsed *parsedFormat, args []interface{}, opts callOptions, emit func(piece string) error) (int, error) {
+	segments, placeholders := parsed.segments, parsed.placeholders
 
 	placeholderValues := make([]interface{}, len(placeholders))
+	placeholderFormats := make([]FormatSpecifier, len(placeholders))
 	autoIndex := 0
 
 	for i, ph := range placeholders {
+		placeholderFormats[i] = ph.Format
+		tagSpec := ""
+
 		switch {
-		// Case 1: "{}" or "{:x}" without explicit positional index or field
+		// Case 0: "{_}" takes the next argument like "{}" but prints nothing
+		case ph.Skip:
+			placeholderValues[i] = skipped
+			autoIndex++
+
+		// Case 1: "{}" or "{:x}" without explicit positional index or field.
+		// Only these and "{_}" advance autoIndex, so as in Rust "{} {0} {}"
+		// reads arguments 0, 0 and 1.
 		case ph.PositionalIndex == nil && len(ph.FieldChain) == 0:
 			val := getArgOrNoValue(autoIndex, args)
 			placeholderValues[i] = val
@@ -26,56 +133,174 @@ func Sprintf(format string, args ...interface{}) string {
 
 		// Case 2: "{2}", "{1}", etc. (positional, no fields)
 		case ph.PositionalIndex != nil && len(ph.FieldChain) == 0:
-			val := getArgOrNoValue(*ph.PositionalIndex, args)
+			val := getPositionalArg(*ph.PositionalIndex, args)
 			placeholderValues[i] = val
 
 		// Case 3: "{2.Name}", etc. (positional with fields)
 		case ph.PositionalIndex != nil && len(ph.FieldChain) > 0:
-			baseVal := getArgOrNoValue(*ph.PositionalIndex, args)
-			placeholderValues[i] = getFieldChainValue(baseVal, ph.FieldChain)
+			baseVal := getPositionalArg(*ph.PositionalIndex, args)
+			placeholderValues[i], tagSpec = getFieldChainValue(baseVal, ph.FieldChain)
 
-		// Case 4: No index, but fields => default to argument #0
+		// Case 4: No index, but fields => the data of SprintfWith, or
+		// argument #0
 		case ph.PositionalIndex == nil && len(ph.FieldChain) > 0:
-			baseVal := getArgOrNoValue(0, args)
-			placeholderValues[i] = getFieldChainValue(baseVal, ph.FieldChain)
+			var baseVal interface{}
+			if opts.hasData {
+				baseVal = unwrapReflectValue(opts.data)
+			} else {
+				baseVal = getArgOrNoValue(0, args)
+			}
+			placeholderValues[i], tagSpec = getFieldChainValue(baseVal, ph.FieldChain)
 		}
+
+		// A "!marker" replaces a missing value or field.
+		if _, missing := placeholderValues[i].(missingValue); missing && ph.HasMissing && !ph.Skip {
+			placeholderValues[i] = missingValue(ph.Missing)
+		}
+
+		// A `fstr:",fmt=..."` tag supplies the spec unless the template has one.
+		if ph.Spec == "" && tagSpec != "" {
+			placeholderFormats[i] = lenientFormatSpecifier(tagSpec)
+		}
+		placeholderFormats[i].locale = opts.locale
 	}
 
-	// Build final output
-	var sb strings.Builder
-	for i, ph := range placeholders {
-		sb.WriteString(segments[i]) // literal text
-		val := placeholderValues[i]
-		formatSpec := placeholderSpecToPrintf(ph.Spec)
-		sb.WriteString(fmt.Sprintf(formatSpec, val))
+	// Emit the output, tracking the column for "@" specs
+	resolved, column := 0, 0
+	strict := strictTypes()
+	var formatErr error // the first *FormatError, reported once all is written
+	write := func(piece string) error {
+		column = advanceColumn(column, piece)
+		return emit(piece)
 	}
-	if len(segments) > len(placeholders) {
-		sb.WriteString(segments[len(placeholders)])
+	for i := range placeholders {
+		if mv, missing := placeholderValues[i].(missingValue); !missing {
+			resolved++
+		} else if opts.requireValues && formatErr == nil && (mv == noValue || mv == invalidField) {
+			formatErr = &FormatError{Placeholder: placeholders[i].Raw, Reason: missingReason(mv)}
+		}
+		fs := placeholderFormats[i]
+		if opts.requireValues && formatErr == nil && !verbHandled(fs, placeholderValues[i]) {
+			formatErr = &FormatError{Placeholder: placeholders[i].Raw, Reason: fmt.Sprintf("unknown verb %q", fs.Verb)}
+		}
+		if err := write(segments[i]); err != nil { // literal text
+			return resolved, err
+		}
+		piece := placeholders[i].Raw
+		if unknownVerbMode() != UnknownVerbLiteral || verbHandled(fs, placeholderValues[i]) {
+			if fs.Column {
+				fs = fs.atColumn(column)
+			}
+			val, color := placeholderValues[i], placeholders[i].Color
+			var text, reason string
+			if strict {
+				reason = typeMismatch(val, fs)
+			}
+			if choice := placeholders[i].Choice; choice != nil {
+				text, color = choice.pick(val, color)
+			} else if reason != "" {
+				text = "<" + reason + ">"
+				if formatErr == nil {
+					formatErr = &FormatError{Placeholder: placeholders[i].Raw, Reason: reason}
+				}
+			} else {
+				text = formatValue(val, fs)
+			}
+			piece = colorize(text, color.pick(val))
+		}
+		if err := write(piece); err != nil {
+			return resolved, err
+		}
 	}
-
-	return sb.String()
+	// trailing literal, possibly ""
+	if err := write(segments[len(placeholders)]); err != nil {
+		return resolved, err
+	}
+	return resolved, formatErr
 }
 
-// Printf calls fmt.Print(...) on Sprintf(format, args...).
+// Printf writes Sprintf(format, args...) to standard output, after the
+// prefix set with SetLinePrefix, if any.
 func Printf(format string, args ...interface{}) (int, error) {
-	return fmt.Print(Sprintf(format, args...))
+	return writeRendered(os.Stdout, linePrefix(), format, args, "")
 }
 
-// Println calls fmt.Println(...) on Sprintf(format, args...).
+// Println writes Sprintf(format, args...) and a single newline to standard
+// output, after the prefix set with SetLinePrefix, if any.
 func Println(format string, args ...interface{}) (int, error) {
-	return fmt.Println(Sprintf(format, args...))
+	return writeRendered(os.Stdout, linePrefix(), format, args, "\n")
 }
 
 // Fprintf is like Printf but allows you to specify an io.Writer.
 func Fprintf(w io.Writer, format string, args ...interface{}) (int, error) {
-	str := Sprintf(format, args...)
-	return fmt.Fprint(w, str)
+	return writeRendered(w, "", format, args, "")
 }
 
-// Fprintln is like Println but allows you to specify an io.Writer.
+// Fprintln is like Println but allows you to specify an io.Writer. Exactly
+// one '\n' is appended; the returned count includes it.
 func Fprintln(w io.Writer, format string, args ...interface{}) (int, error) {
-	str := Sprintf(format, args...)
-	return fmt.Fprintln(w, str)
+	return writeRendered(w, "", format, args, "\n")
+}
+
+// writeRendered writes the formatted output between prefix and suffix to w
+// in one call. A write error takes precedence over a *FormatError.
+func writeRendered(w io.Writer, prefix, format string, args []interface{}, suffix string) (int, error) {
+	s, _, formatErr := render(format, args, callOptions{})
+	n, err := io.WriteString(w, prefix+s+suffix)
+	if err == nil {
+		err = formatErr
+	}
+	return n, err
+}
+
+// SprintfTo is like Sprintf, but appends the output to sb, so a document
+// can be built from several calls without intermediate strings.
+func SprintfTo(sb *strings.Builder, format string, args ...interface{}) error {
+	args, opts := splitOptions(args)
+	_, err := renderTo(getParsedFormat(format), args, opts, func(piece string) error {
+		_, err := sb.WriteString(piece)
+		return err
+	})
+	return err
+}
+
+// Fappendf is like Fprintf, but when w implements io.StringWriter, as
+// bufio.Writer and bytes.Buffer do, each segment and placeholder is written
+// to it directly without building the whole output first. Other writers get
+// the output in a single Write.
+func Fappendf(w io.Writer, format string, args ...interface{}) (int, error) {
+	sw, ok := w.(io.StringWriter)
+	if !ok {
+		return Fprintf(w, format, args...)
+	}
+	args, opts := splitOptions(args)
+	written := 0
+	_, err := renderTo(getParsedFormat(format), args, opts, func(piece string) error {
+		n, err := sw.WriteString(piece)
+		written += n
+		return err
+	})
+	return written, err
+}
+
+// WriteFormatContext is like Fprintf, but writes each literal segment and
+// placeholder to w as soon as it is formatted, and stops with ctx.Err() once
+// ctx is done. It returns the number of bytes written before stopping.
+func WriteFormatContext(ctx context.Context, w io.Writer, format string, args ...interface{}) (int, error) {
+	args, opts := splitOptions(args)
+	written := 0
+	_, err := renderTo(getParsedFormat(format), args, opts, func(piece string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if piece == "" {
+			return nil
+		}
+		n, err := io.WriteString(w, piece)
+		written += n
+		return err
+	})
+	return written, err
 }
 
 // F quickly formats the string.
@@ -85,12 +310,28 @@ func F(format string, args ...interface{}) string {
 
 // P is a shorthand alternative to Printf
 func P(format string, args ...interface{}) (int, error) {
-	return fmt.Print(Sprintf(format, args...))
+	return Printf(format, args...)
 }
 
 // Pln is a shorthand alternative to Println
 func Pln(format string, args ...interface{}) (int, error) {
-	return fmt.Println(Sprintf(format, args...))
+	return Println(format, args...)
+}
+
+// advanceColumn returns the rune column reached by writing s at column col,
+// not counting color escape codes.
+func advanceColumn(col int, s string) int {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		col, s = 0, s[i+1:]
+	}
+	return col + utf8.RuneCountInString(stripColor(s))
+}
+
+// FormatValue formats a single value with an already built spec, producing
+// exactly what a "{:spec}" placeholder would. Having no placeholder text to
+// keep, it treats UnknownVerbLiteral like UnknownVerbPassthrough.
+func FormatValue(v interface{}, spec FormatSpecifier) string {
+	return formatValue(v, spec)
 }
 
 // ------------------------------------------------------------------
@@ -100,17 +341,54 @@ func Pln(format string, args ...interface{}) (int, error) {
 type placeholder struct {
 	PositionalIndex *int
 	FieldChain      []string
-	Spec            string
+	Spec            string // raw text after ':'
+	Format          FormatSpecifier
+	Color           colorRule   // from the "|red" or "|red?neg:green" suffix
+	Choice          *textChoice // from "?cond?then:else"; prints a branch, not the value
+	Raw             string      // the whole placeholder, braces included
+	Skip            bool        // "{_}": consume an argument without printing it
+	Missing         string      // "{name!N/A}": printed instead of a missing value
+	HasMissing      bool        // whether a "!" marker was given
 }
 
-func parseFormat(format string) ([]string, []placeholder) {
-	var segments []string
-	var placeholders []placeholder
-
+// parseFormat splits format into literal segments and placeholders. There is
+// always exactly one more segment than placeholders: segments[i] precedes
+// placeholders[i], and the last segment (empty when the format ends with a
+// placeholder) follows the last one.
+//
+// Text between a pair of rawDelim markers is copied verbatim, without the
+// markers, so braces inside it need no escaping. An unmatched marker is
+// literal text.
+//
+// A segment that appears verbatim in format shares format's bytes, so that a
+// cached format and its literal text are held in memory once. Segments
+// changed by escapes keep their copy; copied is their total length.
+func parseFormat(format string) (segments []string, placeholders []placeholder, copied int) {
 	r := []rune(format)
 	n := len(r)
 	var sb strings.Builder
 
+	// byteOffset returns the byte offset of rune k in format. Segment
+	// boundaries only move forward, so all calls together walk format once.
+	ri, bi := 0, 0
+	byteOffset := func(k int) int {
+		for ; ri < k; ri++ {
+			_, size := utf8.DecodeRuneInString(format[bi:]) // 1 for an invalid byte
+			bi += size
+		}
+		return bi
+	}
+	segStart := 0
+	segment := func(end int) string {
+		lit := sb.String()
+		sb.Reset()
+		if from, to := byteOffset(segStart), byteOffset(end); to-from == len(lit) && format[from:to] == lit {
+			return format[from:to]
+		}
+		copied += len(lit)
+		return lit
+	}
+
 	i := 0
 	for i < n {
 		switch r[i] {
@@ -121,22 +399,34 @@ func parseFormat(format string) ([]string, []placeholder) {
 				i += 2
 				continue
 			}
-			// Start placeholder
-			segments = append(segments, sb.String())
-			sb.Reset()
-
 			closing := findClosingBrace(r, i+1)
 			if closing == -1 {
+				// Unclosed: keep the brace and the rest as literal text.
 				sb.WriteRune('{')
 				i++
 				continue
 			}
+
+			// Start placeholder
+			segments = append(segments, segment(i))
 			inside := string(r[i+1 : closing])
 			i = closing + 1
+			segStart = i
 
 			ph := parsePlaceholder(inside)
+			ph.Raw = "{" + inside + "}"
 			placeholders = append(placeholders, ph)
 
+		case '`':
+			// Check raw region "```...```"
+			if end := findRawEnd(r, i); end != -1 {
+				sb.WriteString(string(r[i+len(rawDelim) : end]))
+				i = end + len(rawDelim)
+				continue
+			}
+			sb.WriteRune('`')
+			i++
+
 		case '}':
 			// Check escaped '}}'
 			if i+1 < n && r[i+1] == '}' {
@@ -152,23 +442,67 @@ func parseFormat(format string) ([]string, []placeholder) {
 			i++
 		}
 	}
-	segments = append(segments, sb.String())
+	segments = append(segments, segment(n))
+
+	return segments, placeholders, copied
+}
+
+// rawDelim opens and closes a raw region in a format string.
+const rawDelim = "```"
+
+// findRawEnd returns the index of the rawDelim closing the raw region that
+// opens at r[start], or -1 if r[start] doesn't open a closed raw region.
+func findRawEnd(r []rune, start int) int {
+	if !hasRawDelim(r, start) {
+		return -1
+	}
+	for i := start + len(rawDelim); i < len(r); i++ {
+		if hasRawDelim(r, i) {
+			return i
+		}
+	}
+	return -1
+}
+
+func hasRawDelim(r []rune, i int) bool {
+	return i+len(rawDelim) <= len(r) && string(r[i:i+len(rawDelim)]) == rawDelim
+}
 
-	return segments, placeholders
+// EscapeBraces doubles every brace in s, so that s can be made part of a
+// format string and print as itself. Values passed as arguments never need
+// it: their text is printed as is and never parsed for placeholders.
+//
+//	fstr.Sprintf("tpl: "+fstr.EscapeBraces("{name}")+" = {}", 1) // "tpl: {name} = 1"
+//
+// A run of three backticks in s still opens a raw region.
+func EscapeBraces(s string) string {
+	return braceEscaper.Replace(s)
 }
 
+var braceEscaper = strings.NewReplacer("{", "{{", "}", "}}")
+
 func parsePlaceholder(inside string) placeholder {
+	// A trailing color applies to any placeholder => "{0.Age:x|red}"
+	inside, color := splitColor(inside)
+
+	// A "?cond?then:else" choice replaces the value => "{ok?true?yes:no}"
+	if name, choice, ok := splitChoice(inside); ok {
+		ph := parsePlaceholder(name)
+		ph.Color, ph.Choice = color, choice
+		return ph
+	}
+
 	// If empty => "{}"
 	if inside == "" {
-		return placeholder{}
+		return placeholder{Format: lenientFormatSpecifier(""), Color: color}
 	}
 	// If starts with ":" => "{:x}", etc.
 	if inside[0] == ':' {
-		return placeholder{Spec: inside[1:]}
+		return placeholder{Spec: inside[1:], Format: lenientFormatSpecifier(inside[1:]), Color: color}
 	}
 
-	// Possibly includes a colon => "0.Name:x"
-	colonIdx := strings.IndexRune(inside, ':')
+	// Possibly includes a colon => "0.Name:x"; "\:" is part of a name
+	colonIdx := indexUnescaped(inside, ':')
 	var mainPart, specPart string
 	if colonIdx >= 0 {
 		mainPart = inside[:colonIdx]
@@ -178,16 +512,53 @@ func parsePlaceholder(inside string) placeholder {
 		specPart = ""
 	}
 
-	argIndex, fieldChain := parseArgIndexAndFieldChain(mainPart)
-	return placeholder{
-		PositionalIndex: argIndex,
-		FieldChain:      fieldChain,
-		Spec:            specPart,
+	ph := placeholder{
+		Spec:   specPart,
+		Format: lenientFormatSpecifier(specPart),
+		Color:  color,
+	}
+	// A missing-value marker ends the argument => "name!N/A", "!-"
+	if bang := indexUnescaped(mainPart, '!'); bang >= 0 {
+		mainPart, ph.Missing, ph.HasMissing = mainPart[:bang], mainPart[bang+1:], true
+	}
+	if mainPart != "" {
+		ph.PositionalIndex, ph.FieldChain = parseArgIndexAndFieldChain(mainPart)
+		ph.Skip = mainPart == "_"
+	}
+	return ph
+}
+
+// indexUnescaped is like strings.IndexByte, but skips a c escaped with a
+// backslash.
+func indexUnescaped(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
 	}
+	return -1
+}
+
+// lastIndexUnescaped is like strings.LastIndexByte, but skips a c escaped
+// with a backslash.
+func lastIndexUnescaped(s string, c byte) int {
+	last := -1
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case c:
+			last = i
+		}
+	}
+	return last
 }
 
 func parseArgIndexAndFieldChain(s string) (*int, []string) {
-	parts := strings.Split(s, ".")
+	parts := splitFieldChain(s)
 	if len(parts[0]) > 0 && isAllDigits(parts[0]) {
 		idx, err := strconv.Atoi(parts[0])
 		if err == nil {
@@ -197,6 +568,30 @@ func parseArgIndexAndFieldChain(s string) (*int, []string) {
 	return nil, parts
 }
 
+// splitFieldChain splits a field chain on '.'. A backslash makes the next
+// character literal, so `{config\.timeout}` names the single key
+// "config.timeout" and `\\` is a backslash.
+func splitFieldChain(s string) []string {
+	if !strings.Contains(s, `\`) {
+		return strings.Split(s, ".")
+	}
+	var parts []string
+	var part strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			part.WriteByte(s[i])
+		case s[i] == '.':
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(s[i])
+		}
+	}
+	return append(parts, part.String())
+}
+
 func isAllDigits(s string) bool {
 	for _, r := range s {
 		if r < '0' || r > '9' {
@@ -206,10 +601,32 @@ func isAllDigits(s string) bool {
 	return true
 }
 
+// findClosingBrace returns the index of the '}' closing the placeholder whose
+// body starts at start, or -1 if it is unclosed. Matching is depth-aware:
+// nested "{...}" pairs, and any braces inside parentheses (as used by
+// conditional branches), belong to the placeholder body.
 func findClosingBrace(r []rune, start int) int {
+	braces, parens := 1, 0
 	for j := start; j < len(r); j++ {
-		if r[j] == '}' {
-			return j
+		switch r[j] {
+		case '(':
+			parens++
+		case ')':
+			if parens > 0 {
+				parens--
+			}
+		case '{':
+			if parens == 0 {
+				braces++
+			}
+		case '}':
+			if parens > 0 {
+				continue
+			}
+			braces--
+			if braces == 0 {
+				return j
+			}
 		}
 	}
 	return -1
@@ -219,89 +636,324 @@ func findClosingBrace(r []rune, start int) int {
 // Field/Map Access
 // ------------------------------------------------------------------
 
+// missingReason describes a missing value for a *FormatError.
+func missingReason(mv missingValue) string {
+	if mv == noValue {
+		return "no argument"
+	}
+	return "no such field or key"
+}
+
+// missingValue stands in for a placeholder that couldn't be resolved. It
+// always prints as its text, whatever the placeholder's verb.
+type missingValue string
+
+const (
+	noValue      missingValue = "<no value>"
+	invalidField missingValue = "<invalid field>"
+	skipped      missingValue = "" // an argument consumed by "{_}"
+)
+
 func getArgOrNoValue(idx int, args []interface{}) interface{} {
 	if idx < 0 || idx >= len(args) {
-		return "<no value>"
+		return noValue
+	}
+	return unwrapReflectValue(args[idx])
+}
+
+// getPositionalArg resolves "{n}". An index past the end of args falls back
+// to key n in argument #0 when that is a map, so int-keyed maps can be used
+// with numeric named placeholders.
+func getPositionalArg(idx int, args []interface{}) interface{} {
+	if idx < len(args) {
+		return getArgOrNoValue(idx, args)
+	}
+	if len(args) > 0 {
+		rv := reflect.Indirect(reflect.ValueOf(getArgOrNoValue(0, args)))
+		if rv.Kind() == reflect.Map {
+			if val := reflectMap(rv, strconv.Itoa(idx)); val != invalidField {
+				return val
+			}
+		}
+	}
+	return noValue
+}
+
+// unwrapReflectValue replaces a reflect.Value with the value it holds, so
+// verbs, field chains and registries see the real type.
+func unwrapReflectValue(arg interface{}) interface{} {
+	rv, ok := arg.(reflect.Value)
+	if !ok {
+		return arg
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	if !rv.CanInterface() {
+		// Obtained through an unexported field; fmt can still print it.
+		return arg
 	}
-	return args[idx]
+	return rv.Interface()
 }
 
-func getFieldChainValue(base interface{}, fields []string) interface{} {
-	current := base
+// getFieldChainValue walks fields from base. It also returns the default
+// spec declared by the last field's `fstr` tag, if any.
+func getFieldChainValue(base interface{}, fields []string) (interface{}, string) {
+	current, spec := base, ""
 	for _, f := range fields {
-		current = reflectFieldOrMapKey(current, f)
+		current, spec = reflectFieldOrMapKey(current, f)
 	}
-	return current
+	return current, spec
 }
 
-func reflectFieldOrMapKey(val interface{}, name string) interface{} {
+func reflectFieldOrMapKey(val interface{}, name string) (interface{}, string) {
 	if val == nil {
-		return "<invalid field>"
+		return invalidField, ""
 	}
 	rv := reflect.ValueOf(val)
-
-	switch rv.Kind() {
-	case reflect.Ptr:
+	for rv.Kind() == reflect.Ptr {
 		if rv.IsNil() {
-			return "<invalid field>"
+			return invalidField, ""
 		}
 		rv = rv.Elem()
-		fallthrough
+	}
 
+	switch rv.Kind() {
 	case reflect.Struct:
 		return reflectField(rv, name)
 
 	case reflect.Map:
-		return reflectMap(rv, name)
+		return reflectMap(rv, name), ""
+
+	case reflect.Slice, reflect.Array:
+		return reflectIndex(rv, name), ""
 
 	default:
-		return "<invalid field>"
+		return invalidField, ""
 	}
 }
 
-func reflectField(rv reflect.Value, fieldName string) interface{} {
-	fv := rv.FieldByName(fieldName)
-	if !fv.IsValid() {
-		return "<invalid field>"
+func reflectField(rv reflect.Value, fieldName string) (interface{}, string) {
+	plan, ok := lookupField(rv.Type(), fieldName)
+	if !ok {
+		return invalidField, ""
+	}
+	tag := plan.tag
+	// FieldByIndexErr reports a nil embedded pointer instead of panicking.
+	fv, err := rv.FieldByIndexErr(plan.field.Index)
+	if tag.HasDefault && (err != nil || fv.IsZero()) {
+		return tag.Default, "" // the tag's spec is meant for the field's type
+	}
+	if err != nil {
+		return invalidField, ""
 	}
 	if !fv.CanInterface() {
-		return "<invalid field>"
+		return invalidField, ""
 	}
-	return fv.Interface()
+	return fv.Interface(), tag.Format
 }
 
 func reflectMap(rv reflect.Value, key string) interface{} {
-	if rv.Type().Key().Kind() == reflect.String {
-		kv := rv.MapIndex(reflect.ValueOf(key))
-		if !kv.IsValid() {
-			return "<invalid field>"
+	kv, ok := mapKey(rv.Type().Key(), key)
+	if !ok {
+		return invalidField
+	}
+	v := rv.MapIndex(kv)
+	if !v.IsValid() {
+		return invalidField
+	}
+	return v.Interface()
+}
+
+// mapKey converts a field-chain segment into a key of type kt, so typed
+// string keys and integer keys can be looked up by name.
+func mapKey(kt reflect.Type, key string) (reflect.Value, bool) {
+	switch kt.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(key).Convert(kt), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(key, 10, kt.Bits())
+		if err != nil {
+			return reflect.Value{}, false
+		}
+		return reflect.ValueOf(n).Convert(kt), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(key, 10, kt.Bits())
+		if err != nil {
+			return reflect.Value{}, false
 		}
-		return kv.Interface()
+		return reflect.ValueOf(n).Convert(kt), true
+	default:
+		return reflect.Value{}, false
 	}
-	return "<invalid field>"
+}
+
+// reflectIndex treats a numeric field-chain segment as a slice or array index.
+func reflectIndex(rv reflect.Value, index string) interface{} {
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= rv.Len() {
+		return invalidField
+	}
+	v := rv.Index(i)
+	if !v.CanInterface() {
+		return invalidField
+	}
+	return v.Interface()
 }
 
 // ------------------------------------------------------------------
 // Format Spec
 // ------------------------------------------------------------------
 
-func placeholderSpecToPrintf(spec string) string {
-	switch spec {
-	case "":
-		return "%v"
-	case "?":
-		return "%+v"
-	case "x":
-		return "%x"
-	case "X":
-		return "%X"
-	case "b":
-		return "%b"
-	case "o":
-		return "%o"
-	case "s":
-		return "%s"
-	default:
-		return "%v"
+// formatValue renders a single placeholder value. Named verbs (see verbs.go)
+// take precedence over registered formatters, which take precedence over
+// enum names and printf-style verbs; fill, alignment and width are applied
+// to the result either way.
+func formatValue(val interface{}, fs FormatSpecifier) string {
+	if fs == (FormatSpecifier{}) && atomic.LoadInt32(&formattersRegistered) == 0 {
+		if s, ok := formatPlain(val); ok {
+			return s
+		}
+	}
+	return pad(formatBody(val, fs), fs, val)
+}
+
+// formatPlain renders the most common bare "{}" arguments without going
+// through reflection or fmt. It only accepts unnamed types, whose output
+// can't be changed by a String method or a registered enum.
+func formatPlain(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		return v, true
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
+
+// formatBody renders val according to fs, without width padding.
+func formatBody(val interface{}, fs FormatSpecifier) string {
+	if mv, ok := val.(missingValue); ok {
+		if (mv == noValue || mv == invalidField) && quiet() {
+			return ""
+		}
+		return string(mv)
+	}
+	if fs.goDirective() {
+		return fmt.Sprintf(fs.Verb, val)
+	}
+	if tmpl := fs.valueTemplate(); tmpl != "" {
+		return formatValueTemplate(tmpl, val)
+	}
+	if chain := fs.projection(); chain != nil {
+		return formatProjection(val, chain)
+	}
+	if keys := fs.keyList(); keys != nil {
+		return formatKeys(val, keys)
+	}
+	// "{:p}" always prints the address, never a formatter's text.
+	if !namedVerb(fs.Verb) && fs.Verb != "p" {
+		if f, arg, ok := formatterFor(val); ok {
+			return f(arg, fs)
+		}
+	}
+	if !fs.knownVerb() && unknownVerbMode() == UnknownVerbError {
+		return "<unknown verb: " + fs.Verb + ">"
+	}
+	if fs.Verb == "" || fs.Verb == "?" {
+		if marker, ok := collectionMarker(val); ok {
+			return marker
+		}
+	}
+	if fs.Verb == "" && atomic.LoadInt32(&formattersRegistered) != 0 {
+		if s, ok := formatNested(val, fs); ok {
+			return s
+		}
+	}
+	if fs.Verb == "" || fs.Verb == "?" {
+		if marker, ok := opaqueMarker(val); ok {
+			return marker
+		}
+		if s, ok := formatSyncSafe(val, fs.Verb == "?"); ok {
+			if fs.Verb == "?" {
+				return escapeControl(s)
+			}
+			return s
+		}
+	}
+	if verb, ok := verbs[fs.Verb]; ok {
+		if fs.HasPrecision && !wholeVerbs[fs.Verb] {
+			return truncateRunes(verb(val), fs.Precision)
+		}
+		return verb(val)
+	}
+	if verb, size, ok := sizedVerb(fs.Verb); ok {
+		return verb(val, size)
+	}
+	if verb, ok := specVerbs[fs.Verb]; ok {
+		return verb(val, fs)
+	}
+	if fs.Verb == "s" && !printsAsString(val) {
+		// fmt would print "%!s(int=5)": use the "{}" text, cut to the precision
+		text := formatBody(val, FormatSpecifier{locale: fs.locale})
+		if fs.HasPrecision {
+			text = truncateRunes(text, fs.Precision)
+		}
+		return text
+	}
+	if fs.Verb == "" {
+		if name, ok := enumName(val); ok {
+			return name
+		}
+		val = normalizeNegativeZero(val)
+	}
+	if fs.integerVerb() {
+		val = plainInteger(val)
+	}
+	if rv := reflect.ValueOf(val); fs.integerVerb() && rv.Kind() == reflect.String {
+		n, ok := parseIntString(rv.String())
+		if !ok {
+			return rv.String() // not a number: print it as is
+		}
+		val = n
+	}
+	if token, ok := specialFloatToken(val); ok {
+		fs.Zero = false // like fmt, never zero-pad Inf or NaN
+		return pad(token, fs, val)
+	}
+	s := fmt.Sprintf(printfDirective(fs, val), val)
+	if strings.Contains(s, "%!") {
+		// fmt rejected the verb for this type, as in "%!d(float64=3.5)":
+		// print the "{}" text instead, unless that's where the "%!" is from.
+		if text := formatBody(val, FormatSpecifier{locale: fs.locale}); !strings.Contains(text, "%!") {
+			if fs.zeroPad() {
+				fs.Zero = false // fmt won't pad it now: pad with the fill instead
+				text = pad(text, fs, val)
+			}
+			return text
+		}
+	}
+	if fs.locale != nil {
+		s = fs.locale.localize(s, val, fs.Verb)
+	} else if fs.Group {
+		s = LocaleUS.localize(s, val, fs.Verb)
+	}
+	if fs.TrailingSign && isNumber(val) && strings.HasPrefix(s, "-") {
+		s = s[1:] + "-"
+	}
+	return s
+}
+
+// truncateRunes cuts s to at most n runes.
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
 	}
+	return string([]rune(s)[:n])
 }
@@ -0,0 +1,150 @@
+package fstr
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseFormatSegmentAlignment(t *testing.T) {
+	tests := []struct {
+		format   string
+		segments []string
+	}{
+		{"", []string{""}},
+		{"text", []string{"text"}},
+		{"{}", []string{"", ""}},
+		{"{}x", []string{"", "x"}},
+		{"x{}", []string{"x", ""}},
+		{"{}{}", []string{"", "", ""}},
+		{"a{}b{}c", []string{"a", "b", "c"}},
+		{"{{}}{}", []string{"{}", ""}},
+		{"a {b c", []string{"a {b c"}},
+		{"{} {b", []string{"", " {b"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			segments, placeholders, _ := parseFormat(tc.format)
+			if len(segments) != len(placeholders)+1 {
+				t.Fatalf("%d segments for %d placeholders", len(segments), len(placeholders))
+			}
+			if len(segments) != len(tc.segments) {
+				t.Fatalf("segments = %q, want %q", segments, tc.segments)
+			}
+			for i := range segments {
+				if segments[i] != tc.segments[i] {
+					t.Errorf("segments = %q, want %q", segments, tc.segments)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestFindClosingBraceDepth(t *testing.T) {
+	tests := []struct {
+		format string
+		inside string // "" when the opening brace is unclosed
+	}{
+		{"{a}", "a"},
+		{"{x?(a}b):(c)} tail", "x?(a}b):(c)"},
+		{"{x?(a{b}):(c)}!", "x?(a{b}):(c)"},
+		{"{:'{}'>6}", ":'{}'>6"},
+		{"{a {b}", ""},
+		{"{(}", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			r := []rune(tc.format)
+			closing := findClosingBrace(r, 1)
+			inside := ""
+			if closing >= 0 {
+				inside = string(r[1:closing])
+			}
+			if inside != tc.inside {
+				t.Errorf("inside = %q, want %q", inside, tc.inside)
+			}
+		})
+	}
+}
+
+func TestFormatPlainMatchesSprint(t *testing.T) {
+	values := []interface{}{
+		"", "text", 0, -42, math.MaxInt64, int64(math.MinInt64),
+		uint64(math.MaxUint64), true, false,
+	}
+	for _, v := range values {
+		got, ok := formatPlain(v)
+		if !ok {
+			t.Errorf("formatPlain(%#v) not handled", v)
+			continue
+		}
+		if want := fmt.Sprint(v); got != want {
+			t.Errorf("formatPlain(%#v) = %q, want %q", v, got, want)
+		}
+	}
+
+	type named string
+	for _, v := range []interface{}{named("x"), int32(1), 1.5, nil} {
+		if _, ok := formatPlain(v); ok {
+			t.Errorf("formatPlain(%#v) handled, want fallback", v)
+		}
+	}
+}
+
+type planInner struct{ City string }
+
+type planOuter struct {
+	Name  string `fstr:"name"`
+	Home  *planInner
+	Past  []planInner
+	Self  *planOuter
+	Count int
+}
+
+func TestPrepareType(t *testing.T) {
+	fieldPlans.Delete(reflect.TypeOf(planOuter{}))
+	fieldPlans.Delete(reflect.TypeOf(planInner{}))
+
+	PrepareType(reflect.TypeOf(&planOuter{}))
+	for _, rt := range []reflect.Type{reflect.TypeOf(planOuter{}), reflect.TypeOf(planInner{})} {
+		if _, ok := fieldPlans.Load(rt); !ok {
+			t.Errorf("no field plans for %v", rt)
+		}
+	}
+
+	v := planOuter{Name: "ann", Home: &planInner{City: "Oslo"}}
+	if got, want := Sprintf("{name} {Name} {Home.City}", v), "ann ann Oslo"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	PrepareType(reflect.TypeOf(0)) // not a struct: no-op
+}
+
+// BenchmarkFirstCall compares the first Sprintf on a struct type with one
+// on a type prepared with PrepareType.
+func BenchmarkFirstCall(b *testing.B) {
+	v := planOuter{Name: "ann", Count: 3}
+	rt := reflect.TypeOf(v)
+	Sprintf("{name} {Count}", v) // parse the format once
+
+	b.Run("Cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fieldPlans.Delete(rt)
+			_ = Sprintf("{name} {Count}", v)
+		}
+	})
+
+	b.Run("Prepared", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			fieldPlans.Delete(rt)
+			PrepareType(rt)
+			b.StartTimer()
+			_ = Sprintf("{name} {Count}", v)
+		}
+	})
+}
@@ -1,9 +1,18 @@
 package fstr_test
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/crazywolf132/fstr"
 )
@@ -291,6 +300,22 @@ func BenchmarkPositional(b *testing.B) {
 	})
 }
 
+// BenchmarkPlainPositional compares the path for formats made only of "{}"
+// and "{n}" with the general one, which ":v" forces for the same output.
+func BenchmarkPlainPositional(b *testing.B) {
+	b.Run("Plain", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = fstr.Sprintf("{0} scored {1} in {2}", "Alice", 42, 3.5)
+		}
+	})
+
+	b.Run("General", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = fstr.Sprintf("{0:v} scored {1:v} in {2:v}", "Alice", 42, 3.5)
+		}
+	})
+}
+
 func BenchmarkStructAccess(b *testing.B) {
 	user := User{Name: "Alice", Age: 30}
 
@@ -365,3 +390,1063 @@ func BenchmarkFormatSpecifiers(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkLiteralOnly(b *testing.B) {
+	const format = "{{\"status\": {{\"ok\": true, \"items\": [{{}}, {{}}]}}}}"
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = fstr.Sprintf(format)
+	}
+}
+
+func BenchmarkSinglePlaceholder(b *testing.B) {
+	b.Run("String", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = fstr.Sprintf("{}", "World")
+		}
+	})
+
+	b.Run("Int", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = fstr.Sprintf("{}", 42)
+		}
+	})
+
+	b.Run("Struct", func(b *testing.B) {
+		user := User{Name: "Alice", Age: 30}
+		for i := 0; i < b.N; i++ {
+			_ = fstr.Sprintf("{}", user)
+		}
+	})
+}
+
+func TestNamedLookupTopLevelKinds(t *testing.T) {
+	const format = "{Name} is {Age}"
+	const want = "Jo is 41"
+
+	person := Person{Name: "Jo", Age: 41}
+	m := map[string]interface{}{"Name": "Jo", "Age": 41}
+
+	tests := []struct {
+		name string
+		arg  interface{}
+	}{
+		{name: "Struct", arg: person},
+		{name: "Pointer_to_struct", arg: &person},
+		{name: "Map", arg: m},
+		{name: "Pointer_to_map", arg: &m},
+		{name: "Typed_map", arg: map[string]string{"Name": "Jo", "Age": "41"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(format, tc.arg); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+type Team struct {
+	Name    string
+	Members []Person
+}
+
+type Region string
+
+func TestFieldChainCollections(t *testing.T) {
+	team := &Team{
+		Name:    "core",
+		Members: []Person{{Name: "Ann", Detail: &Detail{City: "Oslo"}}, {Name: "Ben"}},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Slice_then_struct", "{Members.1.Name}", []interface{}{team}, "Ben"},
+		{"Slice_struct_pointer_struct", "{0.Members.0.Detail.City}", []interface{}{team}, "Oslo"},
+		{"Slice_index_out_of_range", "{Members.5.Name}", []interface{}{team}, "<invalid field>"},
+		{"Slice_index_not_a_number", "{Members.first}", []interface{}{team}, "<invalid field>"},
+		{"Array_index", "{0.1}", []interface{}{[2]string{"x", "y"}}, "y"},
+		{"Pointer_to_map_in_chain", "{0.m.k}", []interface{}{map[string]interface{}{"m": &map[string]int{"k": 3}}}, "3"},
+		{"Typed_string_key", "{eu}", []interface{}{map[Region]int{"eu": 9}}, "9"},
+		{"Int_key_in_chain", "{0.codes.404}", []interface{}{map[string]map[int]string{"codes": {404: "not found"}}}, "not found"},
+		{"Int_key_not_a_number", "{0.codes.x}", []interface{}{map[string]map[int]string{"codes": {}}}, "<invalid field>"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPointerVerb(t *testing.T) {
+	p := &Person{Name: "Ann"}
+	if got, want := fstr.Sprintf("{:p}", p), fmt.Sprintf("%p", p); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := fstr.Sprintf("{0.Detail:p}", Person{Detail: &Detail{}}), "0x"; !strings.HasPrefix(got, want) {
+		t.Errorf("got %q, want prefix %q", got, want)
+	}
+	// Without the verb the pointed-to value is printed, not the address.
+	if got, want := fstr.Sprintf("{}", p), fmt.Sprintf("%v", p); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTrailingSegments(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"{}", "v"},
+		{"{}x", "vx"},
+		{"x{}", "xv"},
+		{"{}{}", "vw"},
+		{"{} and {}.", "v and w."},
+		{"{}}}", "v}"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, "v", "w"); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUnclosedBrace(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Mid_string", "a {b c", nil, "a {b c"},
+		{"At_end", "value {", nil, "value {"},
+		{"Only_brace", "{", nil, "{"},
+		{"After_placeholder", "{} and {b c", []interface{}{1}, "1 and {b c"},
+		{"After_placeholder_at_end", "{}{", []interface{}{1}, "1{"},
+		{"Before_placeholder", "a {b c {}!", []interface{}{1}, "a {b c 1!"},
+		{"Between_placeholders", "{} {x {}", []interface{}{1, 2}, "1 {x 2"},
+		{"Nested_braces_in_fill", "{:'{}'>6}", []interface{}{"ab"}, "{}{}ab"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestNestedBraceLiterals pins depth-aware brace matching: a placeholder
+// runs to the '}' that balances its '{', so inner pairs belong to it. The
+// first '}' used to close it, so "{a{b}c}" was parsed as "{a{b}" then "c}".
+func TestNestedBraceLiterals(t *testing.T) {
+	data := map[string]interface{}{"a{b}c": 1, "b": 2, "x": 3}
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{"Inner_pair_is_part_of_the_name", "{a{b}c}", "1"},
+		{"After_a_placeholder", "{x}{a{b}c}", "31"},
+		{"Escaped_outer_braces", "{{a{b}c}}", "{a2c}"},
+		{"Unbalanced_stays_literal", "{a{b}c", "{a2c"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, data); got != tc.want {
+				t.Errorf("Sprintf(%q) = %q, want %q", tc.format, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSprintfN(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		args      []interface{}
+		want      string
+		wantCount int
+	}{
+		{"All_resolved", "{} {}", []interface{}{1, 2}, "1 2", 2},
+		{"Missing_auto", "{} {} {}", []interface{}{1}, "1 <no value> <no value>", 1},
+		{"Missing_positional", "{0} {3}", []interface{}{"a"}, "a <no value>", 1},
+		{"Missing_named", "{Name} {Nope}", []interface{}{Person{Name: "Ann"}}, "Ann <invalid field>", 1},
+		{"No_placeholders", "plain", nil, "plain", 0},
+		{"Spec_on_missing_prints_sentinel", "{:x}", nil, "<no value>", 0},
+		{"Literal_sentinel_text_counts", "{}", []interface{}{"<no value>"}, "<no value>", 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, n := fstr.SprintfN(tc.format, tc.args...)
+			if got != tc.want || n != tc.wantCount {
+				t.Errorf("got (%q, %d), want (%q, %d)", got, n, tc.want, tc.wantCount)
+			}
+		})
+	}
+}
+
+func TestFprintln(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Appends_single_newline", "Hello, {}!", []interface{}{"World"}, "Hello, World!\n"},
+		{"Trailing_space_kept_without_extra", "{} ", []interface{}{"x"}, "x \n"},
+		{"Already_ends_in_newline", "{}\n", []interface{}{"x"}, "x\n\n"},
+		{"Empty_format", "", nil, "\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := fstr.Fprintln(&buf, tc.format, tc.args...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+			if n != len(tc.want) {
+				t.Errorf("n = %d, want %d", n, len(tc.want))
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	n, err := fstr.Fprintf(&buf, "{} {}", "a", "b")
+	if err != nil || n != 3 || buf.String() != "a b" {
+		t.Errorf("Fprintf: got (%q, %d, %v)", buf.String(), n, err)
+	}
+}
+
+func TestReflectValueArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Int", "{}", []interface{}{reflect.ValueOf(42)}, "42"},
+		{"With_spec", "{:x}", []interface{}{reflect.ValueOf(255)}, "ff"},
+		{"Named_field", "{Name}", []interface{}{reflect.ValueOf(Person{Name: "Ann"})}, "Ann"},
+		{"Positional_field", "{1.k}", []interface{}{0, reflect.ValueOf(map[string]int{"k": 7})}, "7"},
+		{"Verb", "{:yaml}", []interface{}{reflect.ValueOf(map[string]int{"a": 1})}, "a: 1"},
+		{"Zero_value", "{}", []interface{}{reflect.Value{}}, "<nil>"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIntKeyedMapAtTopLevel(t *testing.T) {
+	codes := map[int]string{200: "OK", 404: "Not Found"}
+
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Numeric_placeholder_as_key", "{404}", []interface{}{codes}, "Not Found"},
+		{"Key_with_spec", "[{200:>4}]", []interface{}{codes}, "[  OK]"},
+		{"Pointer_to_map", "{200}", []interface{}{&codes}, "OK"},
+		{"Missing_key", "{500}", []interface{}{codes}, "<no value>"},
+		{"In_range_index_stays_positional", "{1}", []interface{}{codes, "second"}, "second"},
+		{"Explicit_chain", "{0.404}", []interface{}{codes}, "Not Found"},
+		{"Uint_keys", "{7}", []interface{}{map[uint8]bool{7: true}}, "true"},
+		{"Slice_is_not_indexed", "{2}", []interface{}{[]string{"a", "b", "c"}}, "<no value>"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStructWithNameFieldUsesDefaultFormat(t *testing.T) {
+	type Tagged struct {
+		Name string
+		ID   int
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		arg    interface{}
+		want   string
+	}{
+		{"Value", "{}", Tagged{Name: "Ivy", ID: 2}, "{Ivy 2}"},
+		{"Pointer", "{}", &Tagged{Name: "Ivy", ID: 2}, "&{Ivy 2}"},
+		{"Debug", "{:?}", Tagged{Name: "Ivy", ID: 2}, "{Name:Ivy ID:2}"},
+		{"Explicit_field", "{Name}", Tagged{Name: "Ivy", ID: 2}, "Ivy"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.arg); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRawRegions(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"JSON_skeleton", "```{\"user\": {\"id\": ```{}```}}```", []interface{}{42}, `{"user": {"id": 42}}`},
+		{"Escapes_are_verbatim", "```{{}}``` {{}}", nil, "{{}} {}"},
+		{"Empty_region", "a``````b", nil, "ab"},
+		{"Unmatched_delimiter", "```{} left open", []interface{}{1}, "```1 left open"},
+		{"Single_backticks", "`{}`", []interface{}{"code"}, "`code`"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Twice, so the second call renders from the cache.
+			for i := 0; i < 2; i++ {
+				if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+					t.Errorf("call %d: got %q, want %q", i+1, got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPositionalSpecs(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"{0:10.2f}", fmt.Sprintf("%10.2f", 3.14159)},
+		{"{1:08x}", fmt.Sprintf("%08x", 255)},
+		{"{1:#010x}", fmt.Sprintf("%#010x", 255)},
+		{"{0:+.1f}|{1:b}", "+3.1|11111111"},
+		{"[{0:<10.1f}]", "[3.1       ]"},
+		{"[{1:*^7X}]", "[**FF***]"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, 3.14159, 255); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// cancelAfterWriter cancels its context once it has received n writes.
+type cancelAfterWriter struct {
+	buf    bytes.Buffer
+	n      int
+	cancel context.CancelFunc
+}
+
+func (w *cancelAfterWriter) Write(p []byte) (int, error) {
+	w.n--
+	if w.n == 0 {
+		w.cancel()
+	}
+	return w.buf.Write(p)
+}
+
+func TestWriteFormatContext(t *testing.T) {
+	t.Run("Complete", func(t *testing.T) {
+		var buf bytes.Buffer
+		n, err := fstr.WriteFormatContext(context.Background(), &buf, "{}{:.>@8}|{}", "ab", "cd", 3)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := buf.String(), "ab....cd|3"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if n != buf.Len() {
+			t.Errorf("n = %d, want %d", n, buf.Len())
+		}
+	})
+
+	t.Run("Cancelled_mid_stream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		w := &cancelAfterWriter{n: 2, cancel: cancel}
+
+		n, err := fstr.WriteFormatContext(ctx, w, "first {} second {} third", 1, 2)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+		if got, want := w.buf.String(), "first 1"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if n != len("first 1") {
+			t.Errorf("n = %d, want %d", n, len("first 1"))
+		}
+	})
+
+	t.Run("Already_cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var buf bytes.Buffer
+		n, err := fstr.WriteFormatContext(ctx, &buf, "x {}", 1)
+		if !errors.Is(err, context.Canceled) || n != 0 || buf.Len() != 0 {
+			t.Errorf("got n = %d, err = %v, output %q", n, err, buf.String())
+		}
+	})
+}
+
+func TestLiteralOnlyDoesNotAllocate(t *testing.T) {
+	const format = "{{literal}} with }} escapes {{"
+	if got, want := fstr.Sprintf(format), "{literal} with } escapes {"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { _ = fstr.Sprintf(format) }); allocs != 0 {
+		t.Errorf("Sprintf allocated %v times per call, want 0", allocs)
+	}
+}
+
+func TestEscapedFieldSeparator(t *testing.T) {
+	data := map[string]interface{}{
+		"config.timeout": "30s",
+		"config":         map[string]string{"timeout": "10s", "a.b": "nested"},
+		`back\slash`:     "bs",
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{`{config\.timeout}`, "30s"},
+		{`{config.timeout}`, "10s"},
+		{`{config.a\.b}`, "nested"},
+		{`{0.config\.timeout}`, "30s"},
+		{`{back\\slash}`, "bs"},
+		{`{config\.timeout:>5}`, "  30s"},
+		{`{missing\.key}`, "<invalid field>"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, data); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEscapedDelimitersInNames(t *testing.T) {
+	headers := map[string]string{
+		"content:type": "json",
+		"a|b":          "pipe",
+		"a|red":        "not a color",
+		"why?":         "because",
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{`{content\:type}`, "json"},
+		{`{content\:type:>6}`, "  json"},
+		{`{0.content\:type}`, "json"},
+		{`{content\:type|red}`, "\x1b[31mjson\x1b[0m"},
+		{`{content\:type!-}`, "json"},
+		{`{a\|b}`, "pipe"},
+		{`{a\|red}`, "not a color"},
+		{`{a\|b:<5|bold}`, "\x1b[1mpipe \x1b[0m"},
+		{`{why\?}`, "because"},
+		{`{content:type}`, "<invalid field>"}, // "type" is a spec here
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, headers); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// writeOnly hides any WriteString method of the wrapped writer.
+type writeOnly struct{ w io.Writer }
+
+func (w writeOnly) Write(p []byte) (int, error) { return w.w.Write(p) }
+
+func TestFappendf(t *testing.T) {
+	const format = "{name}: {count:>4} items{{}}"
+	args := fstr.Fields("name", "cart", "count", 3)
+	want := "cart:    3 items{}"
+
+	t.Run("Bufio_writer", func(t *testing.T) {
+		var buf bytes.Buffer
+		bw := bufio.NewWriter(&buf)
+		n, err := fstr.Fappendf(bw, format, args)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := bw.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if n != len(want) {
+			t.Errorf("n = %d, want %d", n, len(want))
+		}
+	})
+
+	t.Run("Plain_writer", func(t *testing.T) {
+		var buf bytes.Buffer
+		n, err := fstr.Fappendf(writeOnly{&buf}, format, args)
+		if err != nil || n != len(want) || buf.String() != want {
+			t.Errorf("got %q, n = %d, err = %v", buf.String(), n, err)
+		}
+	})
+
+	t.Run("Write_error", func(t *testing.T) {
+		bw := bufio.NewWriterSize(failingWriter{}, 16)
+		n, err := fstr.Fappendf(bw, "{}", strings.Repeat("x", 40))
+		if err == nil {
+			t.Errorf("expected an error, wrote %d bytes", n)
+		}
+	})
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+func TestAutoIndexIndependentOfExplicit(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"{} {0} {}", "a a b"},
+		{"{1} {} {}", "b a b"},
+		{"{} {2} {} {}", "a c b c"},
+		{"{:>2}{0}{}", " aab"},
+		{"{} {} {} {}", "a b c <no value>"},
+		{"{0.Name} {}", "<invalid field> a"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, "a", "b", "c"); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	// Named placeholders read argument #0 without consuming it either.
+	if got, want := fstr.Sprintf("{Name} {} {}", Person{Name: "Ann"}, "x"), "Ann {Ann  0 <nil>} x"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMissingMarker(t *testing.T) {
+	person := Person{Name: "Ann", Age: 30}
+
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Present_field", "{Name!N/A}", []interface{}{person}, "Ann"},
+		{"Invalid_field", "{Phone!N/A}", []interface{}{person}, "N/A"},
+		{"Missing_map_key", "{city!unknown}", []interface{}{map[string]string{}}, "unknown"},
+		{"Missing_positional", "{0} {1!-}", []interface{}{"a"}, "a -"},
+		{"Missing_auto", "{} {!?}", []interface{}{1}, "1 ?"},
+		{"Blank_marker", "[{Phone!}]", []interface{}{person}, "[]"},
+		{"With_spec", "[{Phone!n/a:>5}]", []interface{}{person}, "[  n/a]"},
+		{"Spec_applies_to_value", "[{Age!n/a:>5}]", []interface{}{person}, "[   30]"},
+		{"Nil_pointer_chain", "{Detail.City!nowhere}", []interface{}{person}, "nowhere"},
+		{"Zero_value_is_present", "{Email!none}", []interface{}{person}, ""},
+		{"Escaped_bang_in_name", `{a\!b!none}`, []interface{}{map[string]int{"a!b": 1}}, "1"},
+		{"Unmarked_keeps_default", "{Phone}", []interface{}{person}, "<invalid field>"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	if _, resolved := fstr.SprintfN("{Phone!N/A}", person); resolved != 0 {
+		t.Errorf("marker counted as resolved: %d", resolved)
+	}
+}
+
+func TestPlainPlaceholdersMatchGeneral(t *testing.T) {
+	args := []interface{}{"s", 42, 3.5, nil, []int{1}, map[string]int(nil), errors.New("e"), &Person{Name: "Ann"}, make(chan int)}
+	formats := []string{"{} {} {} {} {} {} {} {} {}", "{8}{7}{6}{5}{4}{3}{2}{1}{0}", "{} {1} {} {20}"}
+
+	for _, format := range formats {
+		// Fappendf streams through the general renderer.
+		var buf bytes.Buffer
+		if _, err := fstr.Fappendf(&buf, format, args...); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := fstr.Sprintf(format, args...), buf.String(); got != want {
+			t.Errorf("%s: got %q, want %q", format, got, want)
+		}
+	}
+
+	got, resolved := fstr.SprintfN("{} {1} {} {5}", "a", "b")
+	if want := "a b b <no value>"; got != want || resolved != 3 {
+		t.Errorf("got %q (%d resolved), want %q (3 resolved)", got, resolved, want)
+	}
+}
+
+func TestRepeatedAndOutOfOrderIndices(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Repeated", "{0} {0}", []interface{}{"a"}, "a a"},
+		{"Reversed", "{2}{1}{0}", []interface{}{"a", "b", "c"}, "cba"},
+		{"Repeated_out_of_order", "{1} {0} {1} {0}", []interface{}{1, 2}, "2 1 2 1"},
+		{"Auto_after_explicit", "{1} {} {}", []interface{}{"a", "b"}, "b a b"},
+		{"Out_of_range", "{0} {3}", []interface{}{"a"}, "a <no value>"},
+		{"Repeated_with_specs", "{0:>3}|{0:<3}|{0}", []interface{}{"x"}, "  x|x  |x"},
+		{"Reversed_with_specs", "{1:x} {0:b}", []interface{}{5, 255}, "ff 101"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRawBytes(t *testing.T) {
+	type Blob []byte
+	payload := []byte{0xff, 0xfe, 0x00, '\n', 0xc3}
+	want := []byte{'<', 5, 0xff, 0xfe, 0x00, '\n', 0xc3, '>'}
+
+	writers := []struct {
+		name  string
+		write func(w io.Writer) (int, error)
+	}{
+		{"Fprintf", func(w io.Writer) (int, error) {
+			return fstr.Fprintf(w, "<{:bytes}{:bytes}>", []byte{5}, payload)
+		}},
+		{"Fappendf", func(w io.Writer) (int, error) {
+			return fstr.Fappendf(w, "<{:bytes}{:bytes}>", []byte{5}, payload)
+		}},
+		{"WriteFormatContext", func(w io.Writer) (int, error) {
+			return fstr.WriteFormatContext(context.Background(), w, "<{:bytes}{:bytes}>", []byte{5}, Blob(payload))
+		}},
+	}
+
+	for _, tc := range writers {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			n, err := tc.write(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) || n != len(want) {
+				t.Errorf("wrote %d bytes %x, want %x", n, buf.Bytes(), want)
+			}
+		})
+	}
+
+	t.Run("Non_bytes", func(t *testing.T) {
+		if got := fstr.Sprintf("{:bytes} {:bytes}", "a\xffb", 7); got != "a\xffb 7" {
+			t.Errorf("got %q", got)
+		}
+	})
+}
+
+func TestStringVerbOnAnyValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"Int", "{:s}", 42, "42"},
+		{"Float", "{:s}", 2.5, "2.5"},
+		{"Struct", "{:s}", User{Name: "Al", Age: 3}, "{Al 3}"},
+		{"Pointer_to_struct", "{:s}", &User{Name: "Al", Age: 3}, "&{Al 3}"},
+		{"Slice_of_ints", "{:s}", []int{1, 2}, "[1 2]"},
+		{"Nil", "{:s}", nil, "<nil>"},
+		{"Bool", "{:s}", true, "true"},
+		{"Stringer", "{:s}", 1500 * time.Millisecond, "1.5s"},
+		{"Error", "{:s}", errors.New("boom"), "boom"},
+		{"String", "{:s}", "plain", "plain"},
+		{"Bytes", "{:s}", []byte("raw"), "raw"},
+		{"Precision_cuts", "{:.3s}", 123456, "123"},
+		{"Padded", "[{:>5s}]", 42, "[   42]"},
+		{"Padded_left", "[{:<5s}]", 42, "[42   ]"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+type Banner struct{ Text string }
+
+func (b Banner) String() string { return strings.Repeat(b.Text, 4) }
+
+func TestStringerUnderSpec(t *testing.T) {
+	long := Banner{"abcdefghij"}
+	tests := []struct {
+		name   string
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"No_spec", "{}", long, strings.Repeat("abcdefghij", 4)},
+		{"Precision_truncates", "{:.5}", long, "abcde"},
+		{"Precision_with_s", "{:.5s}", long, "abcde"},
+		{"Precision_counts_runes", "{:.5}", Banner{"héllo wörld"}, "héllo"},
+		{"Truncate_then_pad", "[{:>8.3}]", long, "[     abc]"},
+		{"Truncate_then_center", "[{:*^9.3}]", long, "[***abc***]"},
+		{"Width_shorter_than_output", "[{:>5}]", Banner{"ab"}, "[abababab]"},
+		{"Width_longer_than_output", "[{:<10}]", Banner{"ab"}, "[abababab  ]"},
+		{"Positional", "{0:.2}", long, "ab"},
+		{"Field", "{Inner:.4}", struct{ Inner Banner }{long}, "abcd"},
+		{"Pointer", "{:.4}", &long, "abcd"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSprintfTo(t *testing.T) {
+	items := []struct {
+		Name  string
+		Price float64
+	}{{"tea", 3.5}, {"cake", 12}}
+
+	var sb strings.Builder
+	sb.WriteString("# Order\n")
+	for i, item := range items {
+		if err := fstr.SprintfTo(&sb, "{0}. {1.Name:<6}{1.Price:>6.2f}\n", i+1, item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fstr.SprintfTo(&sb, "total: {:.2f} {{EUR}}", 15.5); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "# Order\n1. tea     3.50\n2. cake   12.00\ntotal: 15.50 {EUR}"
+	if got := sb.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSprintfWith(t *testing.T) {
+	ann := Person{Name: "Ann", Age: 41, Detail: &Detail{City: "Oslo"}}
+	tests := []struct {
+		name   string
+		data   interface{}
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"named and auto", ann, "{Name} did {} times", []interface{}{3}, "Ann did 3 times"},
+		{"named between positionals", ann, "{} {Name} {}", []interface{}{"a", "b"}, "a Ann b"},
+		{"explicit index", ann, "{1}/{0} by {Name}", []interface{}{"x", "y"}, "y/x by Ann"},
+		{"nested field with spec", &ann, "{Detail.City:>6}|{:03}", []interface{}{7}, "  Oslo|007"},
+		{"positional fields use args", ann, "{0.Name} and {Name}", []interface{}{User{Name: "Bob"}}, "Bob and Ann"},
+		{"map data", map[string]int{"count": 2}, "{count}+{}", []interface{}{1}, "2+1"},
+		{"no args", ann, "{Name} is {Age}", nil, "Ann is 41"},
+		{"missing positional", ann, "{Name} {}", nil, "Ann <no value>"},
+		{"missing field", ann, "{Nope}", []interface{}{ann}, "<invalid field>"},
+		{"nil data", nil, "{Name}!", []interface{}{ann}, "<invalid field>!"},
+		{"options among args", ann, "{Name}: {:.1f}", []interface{}{fstr.WithLocale(fstr.LocaleGerman), 2.5}, "Ann: 2,5"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.SprintfWith(tc.data, tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSprintfEach(t *testing.T) {
+	people := []interface{}{
+		Person{Name: "Ann", Age: 41},
+		&Person{Name: "Ben", Age: 7, Detail: &Detail{City: "Oslo"}},
+	}
+	rows := []interface{}{
+		map[string]interface{}{"name": "tea", "price": 3.5},
+		map[string]interface{}{"name": "cake"},
+		fstr.Args{"name": "jam", "price": 2.0},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		items  []interface{}
+		sep    string
+		want   string
+	}{
+		{"Structs", "{Name}: {Age}", people, "\n", "Ann: 41\nBen: 7"},
+		{"Specs_and_chains", "{Name:<4}|{Detail.City!-}", people, ";", "Ann |-;Ben |Oslo"},
+		{"Maps", "{name}={price:.2f}", rows, ", ", "tea=3.50, cake=<invalid field>, jam=2.00"},
+		{"Whole_item", "<{}>", []interface{}{1, "two", nil}, "", "<1><two><<nil>>"},
+		{"Single", "{Name}", people[:1], "\n", "Ann"},
+		{"Empty", "{Name}", nil, "\n", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.SprintfEach(tc.format, tc.items, tc.sep); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBareFloatMatchesFmt(t *testing.T) {
+	values := []interface{}{
+		1e20, 1e21, -1e21, 1e100, 123456789.0, 1e6, 999999.0, 3.0, 0.1, 1.5,
+		1e-4, 1e-5, 1e-7, 5e-324, math.MaxFloat64, -0.0,
+		float32(1e20), float32(0.1), float32(1e-7), float32(16777216),
+	}
+	for _, v := range values {
+		want := fmt.Sprint(v)
+		if got := fstr.F("{}", v); got != want {
+			t.Errorf("F(\"{}\", %v) = %q, want %q", v, got, want)
+		}
+		if got := fstr.F("{0}", v); got != want {
+			t.Errorf("F(\"{0}\", %v) = %q, want %q", v, got, want)
+		}
+		if got := fstr.F("{X}", struct{ X interface{} }{v}); got != want {
+			t.Errorf("F(\"{X}\", %v) = %q, want %q", v, got, want)
+		}
+		var sb strings.Builder
+		if _, err := fstr.Fappendf(&sb, "{}", v); err != nil || sb.String() != want {
+			t.Errorf("Fappendf(\"{}\", %v) = %q, %v, want %q", v, sb.String(), err, want)
+		}
+	}
+}
+
+type Severity int
+
+func (s Severity) String() string { return [...]string{"debug", "info", "warn"}[s] }
+
+type Errno uint16
+
+func (e Errno) Error() string { return "errno " + fmt.Sprint(uint16(e)) }
+
+func TestIntegerVerbsBypassStringer(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Bare_uses_String", "{}", []interface{}{Severity(2)}, "warn"},
+		{"Debug_uses_String", "{:?}", []interface{}{Severity(2)}, "warn"},
+		{"String_verb", "{:s}", []interface{}{Severity(2)}, "warn"},
+		{"Decimal", "{:d}", []interface{}{Severity(2)}, "2"},
+		{"Precision_cuts_String", "{:.2}", []interface{}{Severity(2)}, "wa"},
+		{"Hex", "{:x}", []interface{}{Severity(10)}, "a"},
+		{"Upper_hex_alternate", "{:#X}", []interface{}{Severity(10)}, "0XA"},
+		{"Binary", "{:b}", []interface{}{Severity(2)}, "10"},
+		{"Octal", "{:o}", []interface{}{Severity(8)}, "10"},
+		{"Padded", "[{:>4d}|{:<5}]", []interface{}{Severity(1), Severity(1)}, "[   1|info ]"},
+		{"Zero_padded_sign", "{:+04d}", []interface{}{Severity(1)}, "+001"},
+		{"Error_type", "{:x} {}", []interface{}{Errno(255), Errno(255)}, "ff errno 255"},
+		{"Field", "{0.Level:d}={0.Level}", []interface{}{struct{ Level Severity }{1}}, "1=info"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValuesAreNeverParsed(t *testing.T) {
+	values := []string{"{}", "{0}", "{Name}", "{{x}}", "{:>10}", "}{", "{", "```{}```", "{name!N/A}"}
+	for _, v := range values {
+		if got := fstr.Sprintf("[{}]", v); got != "["+v+"]" {
+			t.Errorf("Sprintf(\"[{}]\", %q) = %q", v, got)
+		}
+		if got := fstr.Sprintf("[{s}]", fstr.Args{"s": v}); got != "["+v+"]" {
+			t.Errorf("Sprintf(\"[{s}]\", %q) = %q", v, got)
+		}
+	}
+}
+
+func TestEscapeBraces(t *testing.T) {
+	tests := []struct {
+		in, escaped string
+	}{
+		{"plain", "plain"},
+		{"{}", "{{}}"},
+		{"{name} and {0:>4}", "{{name}} and {{0:>4}}"},
+		{"}{", "}}{{"},
+		{"{{already}}", "{{{{already}}}}"},
+		{"map[a:{1 2}]", "map[a:{{1 2}}]"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got := fstr.EscapeBraces(tc.in)
+			if got != tc.escaped {
+				t.Fatalf("EscapeBraces(%q) = %q, want %q", tc.in, got, tc.escaped)
+			}
+			// Round trip: the escaped text prints as the original.
+			if out := fstr.Sprintf(got); out != tc.in {
+				t.Errorf("Sprintf(%q) = %q, want %q", got, out, tc.in)
+			}
+			if out := fstr.Sprintf("<"+got+"|{}>", 7); out != "<"+tc.in+"|7>" {
+				t.Errorf("embedded: got %q, want %q", out, "<"+tc.in+"|7>")
+			}
+			if err := fstr.Validate(got); err != nil {
+				t.Errorf("Validate(%q) = %v", got, err)
+			}
+		})
+	}
+}
+
+func TestMinIntegers(t *testing.T) {
+	tests := []struct {
+		val  interface{}
+		want int64
+	}{
+		{int64(math.MinInt64), math.MinInt64},
+		{int(math.MinInt64), math.MinInt64},
+		{int32(math.MinInt32), math.MinInt32},
+		{int16(math.MinInt16), math.MinInt16},
+		{int8(math.MinInt8), math.MinInt8},
+		{Severity(math.MinInt64), math.MinInt64},
+	}
+	for _, tc := range tests {
+		want := strconv.FormatInt(tc.want, 10)
+		for _, format := range []string{"{:d}", "{0:d}", "{:>25d}"} {
+			got := fstr.Sprintf(format, tc.val)
+			if strings.TrimLeft(got, " ") != want {
+				t.Errorf("Sprintf(%q, %T) = %q, want %q", format, tc.val, got, want)
+			}
+		}
+		if _, named := tc.val.(Severity); named {
+			continue // {} prints the String method
+		}
+		for _, format := range []string{"{}", "{0}"} {
+			if got := fstr.Sprintf(format, tc.val); got != want {
+				t.Errorf("Sprintf(%q, %T) = %q, want %q", format, tc.val, got, want)
+			}
+		}
+	}
+	if got, want := fstr.Sprintf("{:x}", int64(math.MinInt64)), strconv.FormatInt(math.MinInt64, 16); got != want {
+		t.Errorf("hex: got %q, want %q", got, want)
+	}
+	if got, want := fstr.SprintfLocale(fstr.LocaleUS, "{}", int64(math.MinInt64)), "-9,223,372,036,854,775,808"; got != want {
+		t.Errorf("grouped: got %q, want %q", got, want)
+	}
+}
+
+func TestGoSyntax(t *testing.T) {
+	type Detail struct {
+		Code int
+		Msg  string
+	}
+	type Event struct {
+		Detail Detail
+		Tags   []string
+	}
+	ev := Event{Detail: Detail{Code: 7, Msg: "late"}, Tags: []string{"a", "b"}}
+
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Anonymous_struct", "{:#v}", []interface{}{struct{ X int }{1}}, "struct { X int }{X:1}"},
+		{"Slice", "{:#v}", []interface{}{[]int{1, 2}}, "[]int{1, 2}"},
+		{"String", "{:#v}", []interface{}{"hi"}, `"hi"`},
+		{"Positional_field", "{0.Detail:#v}", []interface{}{ev}, fmt.Sprintf("%#v", ev.Detail)},
+		{"Named_field", "{Tags:#v}", []interface{}{ev}, `[]string{"a", "b"}`},
+		{"Ignores_Stringer", "{:#v}", []interface{}{Severity(2)}, fmt.Sprintf("%#v", Severity(2))},
+		{"Width", "[{:>#12v}]", []interface{}{[]int{1, 2}}, "[ []int{1, 2}]"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("Sprintf(%q) = %q, want %q", tc.format, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNegativeBases(t *testing.T) {
+	tests := []struct {
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"{:x}", -255, "-ff"},
+		{"{:+x}", 255, "+ff"},
+		{"{:+x}", -255, "-ff"},
+		{"{:X}", -255, "-FF"},
+		{"{:#x}", -255, "-0xff"},
+		{"{:o}", -8, "-10"},
+		{"{:O}", -8, "-0o10"},
+		{"{:+o}", 8, "+10"},
+		{"{:b}", -5, "-101"},
+		{"{:+b}", 5, "+101"},
+		{"{:#b}", -5, "-0b101"},
+		{"{:x}", int8(-128), "-80"},
+		{"{:x}", int64(math.MinInt64), "-8000000000000000"},
+		{"{:x}", Severity(-255), "-ff"},
+		{"{:x}", "-255", "-ff"},
+		{"[{:>6x}]", -255, "[   -ff]"},
+		{"[{:06x}]", -255, "[-000ff]"},
+		{"{:trailingx}", -255, "ff-"},
+	}
+	for _, tc := range tests {
+		if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+			t.Errorf("Sprintf(%q, %#v) = %q, want %q", tc.format, tc.val, got, tc.want)
+		}
+	}
+}
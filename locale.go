@@ -0,0 +1,123 @@
+package fstr
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// ------------------------------------------------------------------
+// Locales
+// ------------------------------------------------------------------
+
+// Locale controls how SprintfLocale writes numbers: the decimal separator
+// and how the digits before it are grouped.
+type Locale struct {
+	Decimal string // decimal separator; "" keeps "."
+	Group   string // grouping separator; "" turns grouping off
+	// Grouping lists group sizes from the decimal point leftwards; the last
+	// size repeats. {3} gives 1,234,567 and {3, 2} gives 12,34,567.
+	Grouping []int
+	// GroupAbove leaves numbers with at most this many digits before the
+	// decimal separator ungrouped, as Spanish does with 1234 but 12.345.
+	// 0 groups every number.
+	GroupAbove int
+}
+
+// Built-in locales.
+var (
+	LocaleUS     = Locale{Decimal: ".", Group: ",", Grouping: []int{3}}
+	LocaleGerman = Locale{Decimal: ",", Group: ".", Grouping: []int{3}}
+	LocaleIndian = Locale{Decimal: ".", Group: ",", Grouping: []int{3, 2}}
+)
+
+// SprintfLocale is like Sprintf, but integers and floats formatted with no
+// verb, "d", or a float verb use loc's separators:
+//
+//	fstr.SprintfLocale(fstr.LocaleGerman, "{:.2f}", 1234.5) // "1.234,50"
+//
+// Other verbs, such as "x", and values with a String method are unchanged.
+func SprintfLocale(loc Locale, format string, args ...interface{}) string {
+	s, _, _ := render(format, args, callOptions{locale: &loc})
+	return s
+}
+
+// localize rewrites s, the fmt output for val under verb, with loc's
+// separators.
+func (loc *Locale) localize(s string, val interface{}, verb string) string {
+	switch val.(type) {
+	case fmt.Formatter, fmt.Stringer, error:
+		if verb == "" {
+			return s // fmt printed the value's own text
+		}
+	}
+	switch reflect.ValueOf(val).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if verb != "" && verb != "d" {
+			return s
+		}
+	case reflect.Float32, reflect.Float64:
+		if verb != "" && (len(verb) != 1 || !strings.Contains("eEfFgG", verb)) {
+			return s
+		}
+	default:
+		return s
+	}
+
+	sign := strings.IndexAny(s, "0123456789")
+	if sign < 0 {
+		return s // NaN or Inf
+	}
+	end := sign
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	rest := s[end:]
+	if loc.Decimal != "" && strings.HasPrefix(rest, ".") {
+		rest = loc.Decimal + rest[1:]
+	}
+	// Zeros fmt padded with are grouped too, keeping the width: "-0004200"
+	// becomes "-004,200" rather than "-0,004,200".
+	digits := strings.TrimLeft(s[sign:end], "0")
+	if digits == "" {
+		digits = "0"
+	}
+	grouper := loc
+	if len(digits) <= loc.GroupAbove {
+		grouper = &Locale{} // too short to group
+	}
+	width := utf8.RuneCountInString(s)
+	out := s[:sign] + grouper.group(digits) + rest
+	for digits != s[sign:end] && utf8.RuneCountInString(out) < width {
+		digits = "0" + digits
+		out = s[:sign] + grouper.group(digits) + rest
+	}
+	return out
+}
+
+// group inserts loc.Group between the digit groups of digits.
+func (loc *Locale) group(digits string) string {
+	if loc.Group == "" || len(loc.Grouping) == 0 {
+		return digits
+	}
+	var groups []string
+	sizes := loc.Grouping
+	for digits != "" {
+		size := sizes[0]
+		if len(sizes) > 1 {
+			sizes = sizes[1:]
+		}
+		if size <= 0 || size >= len(digits) {
+			groups = append(groups, digits)
+			break
+		}
+		groups = append(groups, digits[len(digits)-size:])
+		digits = digits[:len(digits)-size]
+	}
+	for i, j := 0, len(groups)-1; i < j; i, j = i+1, j-1 {
+		groups[i], groups[j] = groups[j], groups[i]
+	}
+	return strings.Join(groups, loc.Group)
+}
@@ -0,0 +1,60 @@
+package fstr_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/crazywolf132/fstr"
+)
+
+func TestSprintfLocale(t *testing.T) {
+	spanish := fstr.Locale{Decimal: ",", Group: ".", Grouping: []int{3}, GroupAbove: 4}
+	usAbove3 := fstr.LocaleUS
+	usAbove3.GroupAbove = 3
+	tests := []struct {
+		name   string
+		loc    fstr.Locale
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"US_int", fstr.LocaleUS, "{}", []interface{}{1234567}, "1,234,567"},
+		{"US_negative", fstr.LocaleUS, "{}", []interface{}{-1234567}, "-1,234,567"},
+		{"US_short", fstr.LocaleUS, "{}", []interface{}{999}, "999"},
+		{"US_float", fstr.LocaleUS, "{:.2f}", []interface{}{1234.5}, "1,234.50"},
+		{"US_plus_sign", fstr.LocaleUS, "{:+d}", []interface{}{1000}, "+1,000"},
+		{"German_int", fstr.LocaleGerman, "{:d}", []interface{}{uint64(1234567)}, "1.234.567"},
+		{"German_float", fstr.LocaleGerman, "{:.2}", []interface{}{1234.56}, "1.234,56"},
+		{"German_default_float", fstr.LocaleGerman, "{}", []interface{}{0.5}, "0,5"},
+		{"German_exponent", fstr.LocaleGerman, "{:e}", []interface{}{1234.5}, "1,234500e+03"},
+		{"German_padded", fstr.LocaleGerman, "[{:>10.1f}]", []interface{}{-1234.5}, "[  -1.234,5]"},
+		{"German_zero_padded", fstr.LocaleGerman, "[{:010.1f}]", []interface{}{-1234.5}, "[-001.234,5]"},
+		{"Threshold_three_digits", spanish, "{}", []interface{}{999}, "999"},
+		{"Threshold_four_digits", spanish, "{}", []interface{}{1234}, "1234"},
+		{"Threshold_five_digits", spanish, "{:.2f}", []interface{}{-12345.5}, "-12.345,50"},
+		{"Threshold_three_grouped", usAbove3, "{}", []interface{}{999}, "999"},
+		{"Threshold_four_grouped", usAbove3, "{}", []interface{}{1234}, "1,234"},
+		{"Threshold_zero_padded", spanish, "[{:08}]", []interface{}{1234}, "[00001234]"},
+		{"Indian_zero_padded", fstr.LocaleIndian, "[{:012}]", []interface{}{-4200}, "[-0,00,04,200]"},
+		{"Indian_lakh", fstr.LocaleIndian, "{}", []interface{}{100000}, "1,00,000"},
+		{"Indian_crore", fstr.LocaleIndian, "{:.2f}", []interface{}{12345678.9}, "1,23,45,678.90"},
+		{"Indian_thousand", fstr.LocaleIndian, "{}", []interface{}{1234}, "1,234"},
+		{"Hex_unchanged", fstr.LocaleUS, "{:x}", []interface{}{65535}, "ffff"},
+		{"Stringer_unchanged", fstr.LocaleUS, "{}", []interface{}{1500 * time.Millisecond}, "1.5s"},
+		{"Strings_unchanged", fstr.LocaleGerman, "{} {}", []interface{}{"1234.5", 2}, "1234.5 2"},
+		{"Custom", fstr.Locale{Decimal: "·", Group: "'", Grouping: []int{4}}, "{:.1f}", []interface{}{123456.7}, "12'3456·7"},
+		{"No_grouping", fstr.Locale{Decimal: ","}, "{:.1f}", []interface{}{123456.7}, "123456,7"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.SprintfLocale(tc.loc, tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	if got := fstr.Sprintf("{}", 1234567); got != "1234567" {
+		t.Errorf("Sprintf grouped digits: %q", got)
+	}
+}
@@ -0,0 +1,281 @@
+package fstr
+
+import (
+	"math"
+	"reflect"
+	"sync"
+)
+
+// ------------------------------------------------------------------
+// Call Options
+// ------------------------------------------------------------------
+
+// Option changes how a single call formats. Options can be passed anywhere
+// among the arguments of Sprintf and the other formatting functions; they
+// are removed before placeholders are matched to arguments, so they never
+// shift indices.
+//
+//	fstr.Sprintf("{:.2f}", fstr.WithLocale(fstr.LocaleGerman), 1234.5) // "1.234,50"
+type Option func(*callOptions)
+
+type callOptions struct {
+	locale  *Locale
+	data    interface{} // the data of SprintfWith
+	hasData bool
+
+	requireValues bool // a missing value is a *FormatError, as in SprintfChecked
+}
+
+// WithLocale formats the call's numbers as SprintfLocale does, and makes
+// loc available to formatters through FormatSpecifier.Locale.
+func WithLocale(loc Locale) Option {
+	return func(o *callOptions) { o.locale = &loc }
+}
+
+// splitOptions applies and removes the Options among args. args is returned
+// as is when it holds none.
+func splitOptions(args []interface{}) ([]interface{}, callOptions) {
+	found := 0
+	for _, arg := range args {
+		if _, ok := arg.(Option); ok {
+			found++
+		}
+	}
+	if found == 0 {
+		return args, callOptions{}
+	}
+	opts := new(callOptions) // only escapes when there are options
+	kept := make([]interface{}, 0, len(args)-found)
+	for _, arg := range args {
+		opt, ok := arg.(Option)
+		if !ok {
+			kept = append(kept, arg)
+		} else if opt != nil {
+			opt(opts)
+		}
+	}
+	return kept, *opts
+}
+
+// ------------------------------------------------------------------
+// Options
+// ------------------------------------------------------------------
+
+// options holds the package-wide settings changed through the Set*
+// functions. It is safe for concurrent use.
+var options = struct {
+	sync.RWMutex
+	unknownVerb UnknownVerbMode
+	nilMap      string
+	nilSlice    string
+	shortOpaque bool
+	keepNegZero bool
+	strictTypes bool
+	quiet       bool
+	floatTokens *[3]string // +Inf, -Inf and NaN; nil keeps fmt's output
+	linePrefix  func() string
+}{
+	nilMap:   "{}",
+	nilSlice: "[]",
+}
+
+// UnknownVerbMode selects what a placeholder does when its spec names a verb
+// that is neither a printf-style letter nor a registered verb. A value with a
+// registered formatter is always given its verb, whatever the mode.
+type UnknownVerbMode int
+
+const (
+	// UnknownVerbPassthrough formats the value as with "{}". This is the default.
+	UnknownVerbPassthrough UnknownVerbMode = iota
+	// UnknownVerbLiteral leaves the placeholder text in the output unchanged.
+	UnknownVerbLiteral
+	// UnknownVerbError replaces the placeholder with "<unknown verb: name>".
+	UnknownVerbError
+)
+
+// SetUnknownVerbMode sets how unknown verbs are handled.
+func SetUnknownVerbMode(mode UnknownVerbMode) {
+	options.Lock()
+	defer options.Unlock()
+	options.unknownVerb = mode
+}
+
+func unknownVerbMode() UnknownVerbMode {
+	options.RLock()
+	defer options.RUnlock()
+	return options.unknownVerb
+}
+
+// SetStrictTypes sets whether printf-style verbs check the type of their
+// value. When on, a verb that doesn't fit, such as "{:f}" on a string or
+// "{:x}" on a float, prints "<verb f needs a float, got string>" and makes
+// the functions that return an error report a *FormatError. When off, the
+// default, such values are formatted as well as fmt allows, and integer
+// verbs accept strings holding a number.
+func SetStrictTypes(strict bool) {
+	options.Lock()
+	defer options.Unlock()
+	options.strictTypes = strict
+}
+
+func strictTypes() bool {
+	options.RLock()
+	defer options.RUnlock()
+	return options.strictTypes
+}
+
+// SetQuiet sets whether placeholders without a value print nothing instead
+// of "<no value>" or "<invalid field>". A "{name!marker}" still prints its
+// own marker. This suits user-facing text where a gap reads better than a
+// sentinel.
+func SetQuiet(q bool) {
+	options.Lock()
+	defer options.Unlock()
+	options.quiet = q
+}
+
+func quiet() bool {
+	options.RLock()
+	defer options.RUnlock()
+	return options.quiet
+}
+
+// SetNilCollectionMarkers sets what "{}" and "{:?}" print for a nil map and a
+// nil slice. The defaults are "{}" and "[]"; an empty but non-nil map always
+// prints as "{}" and an empty slice as "[]".
+func SetNilCollectionMarkers(nilMap, nilSlice string) {
+	options.Lock()
+	defer options.Unlock()
+	options.nilMap, options.nilSlice = nilMap, nilSlice
+}
+
+// collectionMarker returns the marker for val if it is a nil or empty map, or
+// a nil slice.
+func collectionMarker(val interface{}) (string, bool) {
+	rv := reflect.ValueOf(val)
+	switch {
+	case rv.Kind() == reflect.Map && rv.IsNil():
+		options.RLock()
+		defer options.RUnlock()
+		return options.nilMap, true
+	case rv.Kind() == reflect.Map && rv.Len() == 0:
+		return "{}", true
+	case rv.Kind() == reflect.Slice && rv.IsNil():
+		options.RLock()
+		defer options.RUnlock()
+		return options.nilSlice, true
+	}
+	return "", false
+}
+
+// SetShortOpaqueMarkers sets whether "{}" and "{:?}" print channels and
+// functions as a bare "<chan>" or "<func>". By default the marker includes
+// the type, as in "<chan int>" or "<func() error>".
+func SetShortOpaqueMarkers(short bool) {
+	options.Lock()
+	defer options.Unlock()
+	options.shortOpaque = short
+}
+
+// opaqueMarker returns the marker for val if it is a channel or function,
+// which have no printable contents.
+func opaqueMarker(val interface{}) (string, bool) {
+	rv := reflect.ValueOf(val)
+	var short string
+	switch rv.Kind() {
+	case reflect.Chan:
+		short = "<chan>"
+	case reflect.Func:
+		short = "<func>"
+	default:
+		return "", false
+	}
+	options.RLock()
+	defer options.RUnlock()
+	if options.shortOpaque {
+		return short, true
+	}
+	return "<" + rv.Type().String() + ">", true
+}
+
+// SetKeepNegativeZero sets whether a negative zero float keeps its sign when
+// formatted without a verb. By default "{}" prints -0.0 as "0".
+func SetKeepNegativeZero(keep bool) {
+	options.Lock()
+	defer options.Unlock()
+	options.keepNegZero = keep
+}
+
+// normalizeNegativeZero returns val with a negative zero float replaced by
+// positive zero of the same type, unless SetKeepNegativeZero(true) was called.
+func normalizeNegativeZero(val interface{}) interface{} {
+	rv := reflect.ValueOf(val)
+	if !isFloat(val) || rv.Float() != 0 || !math.Signbit(rv.Float()) {
+		return val
+	}
+	options.RLock()
+	defer options.RUnlock()
+	if options.keepNegZero {
+		return val
+	}
+	return reflect.Zero(rv.Type()).Interface()
+}
+
+// SetSpecialFloatTokens sets what infinities and NaN print as, for example
+// "∞", "-∞" and "NaN". Width and alignment still apply to the tokens. The
+// defaults are fmt's "+Inf", "-Inf" and "NaN"; passing exactly those restores
+// fmt's handling, including '+' on NaN.
+func SetSpecialFloatTokens(posInf, negInf, nan string) {
+	options.Lock()
+	defer options.Unlock()
+	if posInf == "+Inf" && negInf == "-Inf" && nan == "NaN" {
+		options.floatTokens = nil
+		return
+	}
+	options.floatTokens = &[3]string{posInf, negInf, nan}
+}
+
+// specialFloatToken returns the custom token for val if it is an infinity or
+// NaN and SetSpecialFloatTokens has changed the defaults.
+func specialFloatToken(val interface{}) (string, bool) {
+	if !isFloat(val) {
+		return "", false
+	}
+	f := reflect.ValueOf(val).Float()
+	if !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return "", false
+	}
+	options.RLock()
+	defer options.RUnlock()
+	if options.floatTokens == nil {
+		return "", false
+	}
+	switch {
+	case math.IsInf(f, 1):
+		return options.floatTokens[0], true
+	case math.IsInf(f, -1):
+		return options.floatTokens[1], true
+	default:
+		return options.floatTokens[2], true
+	}
+}
+
+// SetLinePrefix sets a function whose result Printf and Println (and P and
+// Pln) write before their output, such as a timestamp or log level. It is
+// called once per call. The Fprint and Sprint variants are unaffected. A nil
+// prefix turns the feature off.
+func SetLinePrefix(prefix func() string) {
+	options.Lock()
+	defer options.Unlock()
+	options.linePrefix = prefix
+}
+
+func linePrefix() string {
+	options.RLock()
+	prefix := options.linePrefix
+	options.RUnlock()
+	if prefix == nil {
+		return ""
+	}
+	return prefix()
+}
@@ -0,0 +1,476 @@
+package fstr_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/crazywolf132/fstr"
+)
+
+func TestUnknownVerbMode(t *testing.T) {
+	t.Cleanup(func() { fstr.SetUnknownVerbMode(fstr.UnknownVerbPassthrough) })
+
+	tests := []struct {
+		name string
+		mode fstr.UnknownVerbMode
+		want string
+	}{
+		{"Passthrough", fstr.UnknownVerbPassthrough, "n=42 hex=2a"},
+		{"Literal", fstr.UnknownVerbLiteral, "n={:bogus} hex=2a"},
+		{"Error", fstr.UnknownVerbError, "n=<unknown verb: bogus> hex=2a"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fstr.SetUnknownVerbMode(tc.mode)
+			if got := fstr.Sprintf("n={:bogus} hex={:x}", 42, 42); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	fstr.SetUnknownVerbMode(fstr.UnknownVerbLiteral)
+	if got, want := fstr.Sprintf("{0.Age:>5zz}", Person{Age: 3}), "{0.Age:>5zz}"; got != want {
+		t.Errorf("literal with field chain: got %q, want %q", got, want)
+	}
+
+	t.Run("Formatter_verbs", func(t *testing.T) {
+		type Celsius float64
+		fstr.RegisterFormatter(reflect.TypeOf(Celsius(0)), func(v interface{}, spec fstr.FormatSpecifier) string {
+			if spec.Verb == "fahrenheit" {
+				return fmt.Sprintf("%g°F", float64(v.(Celsius))*9/5+32)
+			}
+			return fmt.Sprintf("%g°C", float64(v.(Celsius)))
+		})
+		t.Cleanup(func() { fstr.RegisterFormatter(reflect.TypeOf(Celsius(0)), nil) })
+
+		for _, mode := range []fstr.UnknownVerbMode{fstr.UnknownVerbPassthrough, fstr.UnknownVerbLiteral, fstr.UnknownVerbError} {
+			fstr.SetUnknownVerbMode(mode)
+			got := fstr.Sprintf("{:fahrenheit} {:fahrenheit}", Celsius(100), 7)
+			want := map[fstr.UnknownVerbMode]string{
+				fstr.UnknownVerbPassthrough: "212°F 7",
+				fstr.UnknownVerbLiteral:     "212°F {:fahrenheit}",
+				fstr.UnknownVerbError:       "212°F <unknown verb: fahrenheit>",
+			}[mode]
+			if got != want {
+				t.Errorf("mode %d: got %q, want %q", mode, got, want)
+			}
+		}
+	})
+}
+
+func TestNilCollections(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"Nil_map", "{}", map[string]int(nil), "{}"},
+		{"Nil_slice", "{}", []int(nil), "[]"},
+		{"Nil_map_debug", "{:?}", map[string]int(nil), "{}"},
+		{"Nil_map_yaml", "{:yaml}", map[string]int(nil), "{}"},
+		{"Nil_slice_yaml", "{:yaml}", []string(nil), "[]"},
+		{"Empty_map", "{}", map[string]int{}, "{}"},
+		{"Empty_map_debug", "{:?}", map[string]int{}, "{}"},
+		{"Empty_map_padded", "[{:>4}]", map[int]bool{}, "[  {}]"},
+		{"Empty_slice", "{}", []int{}, "[]"},
+		{"Nil_map_padded", "[{:>4}]", map[string]int(nil), "[  {}]"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Custom_markers", func(t *testing.T) {
+		fstr.SetNilCollectionMarkers("<nil map>", "<nil slice>")
+		t.Cleanup(func() { fstr.SetNilCollectionMarkers("{}", "[]") })
+
+		got := fstr.Sprintf("{} {} {} {}", map[int]int(nil), []byte(nil), map[int]int{}, []int{})
+		if want := "<nil map> <nil slice> {} []"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+func TestOpaqueMarkers(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"Chan", "{}", make(chan int), "<chan int>"},
+		{"Receive_only_chan", "{}", make(<-chan string), "<<-chan string>"},
+		{"Nil_chan", "{:?}", (chan error)(nil), "<chan error>"},
+		{"Func", "{}", func() error { return nil }, "<func() error>"},
+		{"Func_with_args", "{}", strings.Repeat, "<func(string, int) string>"},
+		{"Padded", "[{:>12}]", make(chan bool), "[ <chan bool>]"},
+		{"Go_directive_unchanged", "{:%T}", make(chan int), "chan int"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Short_markers", func(t *testing.T) {
+		fstr.SetShortOpaqueMarkers(true)
+		t.Cleanup(func() { fstr.SetShortOpaqueMarkers(false) })
+
+		got := fstr.Sprintf("{} {}", make(chan int), func() {})
+		if want := "<chan> <func>"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+func TestNegativeZero(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+
+	tests := []struct {
+		name   string
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"Negative_zero", "{}", negZero, "0"},
+		{"Negative_zero_float32", "{}", float32(negZero), "0"},
+		{"Positive_zero", "{}", 0.0, "0"},
+		{"Negative_zero_with_precision", "{:.2}", negZero, "0.00"},
+		{"Explicit_verb_keeps_sign", "{:f}", negZero, "-0.000000"},
+		{"Subnormal", "{}", math.SmallestNonzeroFloat64, "5e-324"},
+		{"Negative_subnormal", "{}", -math.SmallestNonzeroFloat64, "-5e-324"},
+		{"Tiny_value", "{}", 1.5e-300, "1.5e-300"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Keep_sign", func(t *testing.T) {
+		fstr.SetKeepNegativeZero(true)
+		t.Cleanup(func() { fstr.SetKeepNegativeZero(false) })
+
+		if got, want := fstr.Sprintf("{}", negZero), "-0"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+func TestSignedZero(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+
+	tests := []struct {
+		name   string
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"Positive_zero_fixed", "{:+.2f}", 0.0, "+0.00"},
+		{"Negative_zero_fixed", "{:+.2f}", negZero, "-0.00"},
+		{"Positive_zero_float32", "{:+.1f}", float32(0), "+0.0"},
+		{"Positive_zero_padded", "{:+08.2f}", 0.0, "+0000.00"},
+		{"Positive_zero_aligned", "[{:>+7.2f}]", 0.0, "[  +0.00]"},
+		{"Positive_zero_exponent", "{:+.1e}", 0.0, "+0.0e+00"},
+		{"Positive_zero_no_verb", "{:+.2}", 0.0, "+0.00"},
+		{"Negative_zero_no_verb", "{:+.2}", negZero, "+0.00"},
+		{"Negative_zero_shortest", "{:+}", negZero, "+0"},
+		{"Rounds_to_zero", "{:+.2f}", 0.001, "+0.00"},
+		{"Negative_rounds_to_zero", "{:+.2f}", -0.001, "-0.00"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Keep_sign_without_verb", func(t *testing.T) {
+		fstr.SetKeepNegativeZero(true)
+		t.Cleanup(func() { fstr.SetKeepNegativeZero(false) })
+
+		if got, want := fstr.Sprintf("{:+.2}", negZero), "-0.00"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+func TestSpecialFloatTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"Positive_infinity", "{}", math.Inf(1), "∞"},
+		{"Negative_infinity", "{}", math.Inf(-1), "-∞"},
+		{"NaN", "{}", math.NaN(), "not a number"},
+		{"Float32", "{}", float32(math.Inf(1)), "∞"},
+		{"Precision_verb", "{:.2f}", math.Inf(-1), "-∞"},
+		{"Right_aligned", "[{:>4}]", math.Inf(1), "[   ∞]"},
+		{"Zero_pad_uses_spaces", "[{:04}]", math.Inf(1), "[   ∞]"},
+		{"Finite_unchanged", "{}", 1.5, "1.5"},
+	}
+
+	fstr.SetSpecialFloatTokens("∞", "-∞", "not a number")
+	t.Cleanup(func() { fstr.SetSpecialFloatTokens("+Inf", "-Inf", "NaN") })
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Empty_tokens", func(t *testing.T) {
+		fstr.SetSpecialFloatTokens("", "", "")
+		got := fstr.Sprintf("[{}|{}|{}]", math.Inf(1), math.Inf(-1), math.NaN())
+		if want := "[||]"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		fstr.SetSpecialFloatTokens("+Inf", "-Inf", "NaN")
+		got := fstr.Sprintf("{} {} {} {:08.2f}", math.Inf(1), math.Inf(-1), math.NaN(), math.Inf(1))
+		if want := "+Inf -Inf NaN     +Inf"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+func TestLinePrefix(t *testing.T) {
+	calls := 0
+	fstr.SetLinePrefix(func() string {
+		calls++
+		return fmt.Sprintf("[%d] ", calls)
+	})
+	t.Cleanup(func() { fstr.SetLinePrefix(nil) })
+
+	got := captureStdout(t, func() {
+		fstr.Println("starting {}", "api")
+		fstr.Printf("{} of {}\n", 1, 2)
+		fstr.Pln("done")
+	})
+	if want := "[1] starting api\n[2] 1 of 2\n[3] done\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	var buf bytes.Buffer
+	fstr.Fprintln(&buf, "{}", "unprefixed")
+	if got, want := buf.String(), "unprefixed\n"; got != want {
+		t.Errorf("Fprintln: got %q, want %q", got, want)
+	}
+
+	fstr.SetLinePrefix(nil)
+	if got, want := captureStdout(t, func() { fstr.Println("plain") }), "plain\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// captureStdout returns what f writes to os.Stdout.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	f()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+type Money int64 // cents
+
+func TestCallOptions(t *testing.T) {
+	fstr.RegisterFormatter(reflect.TypeOf(Money(0)), func(v interface{}, spec fstr.FormatSpecifier) string {
+		cents := int64(v.(Money))
+		loc, ok := spec.Locale()
+		if !ok {
+			loc = fstr.LocaleUS
+		}
+		if spec.Verb != "currency" {
+			return fmt.Sprint(cents)
+		}
+		return fstr.SprintfLocale(loc, "{:.2f} €", float64(cents)/100)
+	})
+	t.Cleanup(func() { fstr.RegisterFormatter(reflect.TypeOf(Money(0)), nil) })
+
+	german := fstr.WithLocale(fstr.LocaleGerman)
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Currency_with_locale", "{:currency}", []interface{}{german, Money(123456)}, "1.234,56 €"},
+		{"Currency_without_locale", "{:currency}", []interface{}{Money(123456)}, "1,234.56 €"},
+		{"Option_last", "{} {}", []interface{}{1234.5, 2, german}, "1.234,5 2"},
+		{"Option_does_not_shift_indices", "{1} {0}", []interface{}{"a", german, "b"}, "b a"},
+		{"Named_args", "{n}", []interface{}{german, fstr.Args{"n": 1000}}, "1.000"},
+		{"Compact_verb", "{:compact}", []interface{}{german, 12345}, "12,3k"},
+		{"Last_option_wins", "{}", []interface{}{german, fstr.WithLocale(fstr.LocaleUS), 1000}, "1,000"},
+		{"Nil_option", "{}", []interface{}{fstr.Option(nil), 1000}, "1000"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Writers", func(t *testing.T) {
+		var buf bytes.Buffer
+		if _, err := fstr.Fappendf(&buf, "{:d}", german, 1000000); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := buf.String(), "1.000.000"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("CheckArgs_ignores_options", func(t *testing.T) {
+		if err := fstr.CheckArgs("{}", german, 1); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestStrictTypes(t *testing.T) {
+	t.Cleanup(func() { fstr.SetStrictTypes(false) })
+
+	tests := []struct {
+		name    string
+		format  string
+		val     interface{}
+		lenient string
+		strict  string
+		err     string // empty when strict mode accepts the value
+	}{
+		{"Float_on_string", "{:.2f}", "3.5", "3.5", "<verb f needs a float, got string>",
+			"fstr: {:.2f}: verb f needs a float, got string"},
+		{"Hex_on_bool", "{:x}", true, "true", "<verb x needs an integer or []byte, got bool>",
+			"fstr: {:x}: verb x needs an integer or []byte, got bool"},
+		{"Hex_on_float", "{:x}", 1.5, "0x1.8p+00", "<verb x needs an integer or []byte, got float64>",
+			"fstr: {:x}: verb x needs an integer or []byte, got float64"},
+		{"Decimal_on_numeric_string", "{:d}", "42", "42", "<verb d needs an integer, got string>",
+			"fstr: {:d}: verb d needs an integer, got string"},
+		{"Float_on_int", "{:.1f}", 3, "3", "<verb f needs a float, got int>",
+			"fstr: {:.1f}: verb f needs a float, got int"},
+		{"Hex_on_bytes", "{:x}", []byte("hi"), "6869", "6869", ""},
+		{"Hex_on_named_int", "{:#x}", Perm(255), "0xff", "0xff", ""},
+		{"Float_on_float32", "{:.1f}", float32(2.5), "2.5", "2.5", ""},
+		{"Bool_verb", "{:t}", false, "false", "false", ""},
+		{"Untyped_verb", "{:>4}", "ab", "  ab", "  ab", ""},
+		{"Decimal_on_float", "{:d}", 3.5, "3.5", "<verb d needs an integer, got float64>",
+			"fstr: {:d}: verb d needs an integer, got float64"},
+		{"Decimal_on_float_padded", "[{:>6d}]", 3.5, "[   3.5]", "[<verb d needs an integer, got float64>]",
+			"fstr: {:>6d}: verb d needs an integer, got float64"},
+		{"Decimal_on_float_zero_padded", "[{:06d}]", 3.5, "[   3.5]", "[<verb d needs an integer, got float64>]",
+			"fstr: {:06d}: verb d needs an integer, got float64"},
+		{"Percent_in_own_text", "{:d}", "100%!", "100%!", "<verb d needs an integer, got string>",
+			"fstr: {:d}: verb d needs an integer, got string"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fstr.SetStrictTypes(false)
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.lenient {
+				t.Errorf("lenient: got %q, want %q", got, tc.lenient)
+			}
+
+			fstr.SetStrictTypes(true)
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.strict {
+				t.Errorf("strict: got %q, want %q", got, tc.strict)
+			}
+			var buf bytes.Buffer
+			_, err := fstr.Fprintf(&buf, tc.format, tc.val)
+			if tc.err == "" {
+				return
+			}
+			var fe *fstr.FormatError
+			if !errors.As(err, &fe) || err.Error() != tc.err {
+				t.Errorf("Fprintf error = %v, want *FormatError %q", err, tc.err)
+			}
+			if buf.String() != tc.strict {
+				t.Errorf("Fprintf wrote %q, want %q", buf.String(), tc.strict)
+			}
+		})
+	}
+
+	// The output is complete and the error names the first mismatch.
+	fstr.SetStrictTypes(true)
+	var buf bytes.Buffer
+	_, err := fstr.Fappendf(&buf, "{:d} {:.1f} {:f}", "7", 2.5, "x")
+	if want := "<verb d needs an integer, got string> 2.5 <verb f needs a float, got string>"; buf.String() != want {
+		t.Errorf("Fappendf wrote %q, want %q", buf.String(), want)
+	}
+	if want := "fstr: {:d}: verb d needs an integer, got string"; err == nil || err.Error() != want {
+		t.Errorf("Fappendf error = %v, want %q", err, want)
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	fstr.SetQuiet(true)
+	t.Cleanup(func() { fstr.SetQuiet(false) })
+
+	person := Person{Name: "Ann"}
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Missing_auto", "a={} b={}", []interface{}{1}, "a=1 b="},
+		{"Missing_positional", "[{3}]", []interface{}{1}, "[]"},
+		{"Invalid_field", "Hi {Name}{Phone}!", []interface{}{person}, "Hi Ann!"},
+		{"Nil_pointer_chain", "[{Detail.City}]", []interface{}{person}, "[]"},
+		{"Missing_map_key", "[{city}]", []interface{}{map[string]string{}}, "[]"},
+		{"Padding_kept", "[{Phone:>3}]", []interface{}{person}, "[   ]"},
+		{"Own_marker_wins", "{Phone!n/a}", []interface{}{person}, "n/a"},
+		{"Plain_path", "{0}{1}", []interface{}{"x"}, "x"},
+		{"Nil_value_is_present", "{}", []interface{}{nil}, "<nil>"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	fstr.SetQuiet(false)
+	if got, want := fstr.Sprintf("{} {Phone}", person), "{Ann  0 <nil>} <invalid field>"; got != want {
+		t.Errorf("after SetQuiet(false): got %q, want %q", got, want)
+	}
+}
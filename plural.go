@@ -0,0 +1,87 @@
+package fstr
+
+import "strings"
+
+// ------------------------------------------------------------------
+// Plurals
+// ------------------------------------------------------------------
+
+// Plural picks the form for count n in language lang and formats it with n
+// as its argument. Forms are keyed by CLDR plural category: "zero", "one",
+// "two", "few", "many" and "other". A category with no form falls back to
+// "other".
+//
+//	fstr.Plural("en", 3, map[string]string{"one": "{} item", "other": "{} items"})
+//	// "3 items"
+//
+// lang is a language code such as "en" or "pt-BR"; only the language part is
+// used. Rules exist for ar, cs, de, en, es, fr, it, ja, ko, nl, pl, pt, ru,
+// sk, sv, uk and zh, and other languages follow English.
+func Plural(lang string, n int, forms map[string]string) string {
+	form, ok := forms[pluralCategory(lang, n)]
+	if !ok {
+		form = forms["other"]
+	}
+	return Sprintf(form, n)
+}
+
+// pluralCategory returns the CLDR plural category of integer n in lang.
+func pluralCategory(lang string, n int) string {
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if n < 0 {
+		n = -n
+	}
+	mod10, mod100 := n%10, n%100
+
+	switch strings.ToLower(lang) {
+	case "ja", "ko", "zh":
+		return "other"
+	case "fr":
+		if n <= 1 {
+			return "one"
+		}
+	case "ru", "uk":
+		switch {
+		case mod10 == 1 && mod100 != 11:
+			return "one"
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return "few"
+		}
+		return "many"
+	case "pl":
+		switch {
+		case n == 1:
+			return "one"
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return "few"
+		}
+		return "many"
+	case "cs", "sk":
+		switch {
+		case n == 1:
+			return "one"
+		case n >= 2 && n <= 4:
+			return "few"
+		}
+	case "ar":
+		switch {
+		case n == 0:
+			return "zero"
+		case n == 1:
+			return "one"
+		case n == 2:
+			return "two"
+		case mod100 >= 3 && mod100 <= 10:
+			return "few"
+		case mod100 >= 11:
+			return "many"
+		}
+	default: // en, de, es, it, nl, pt, sv and unknown languages
+		if n == 1 {
+			return "one"
+		}
+	}
+	return "other"
+}
@@ -0,0 +1,54 @@
+package fstr_test
+
+import (
+	"testing"
+
+	"github.com/crazywolf132/fstr"
+)
+
+func TestPlural(t *testing.T) {
+	english := map[string]string{"one": "{} item", "other": "{} items"}
+	russian := map[string]string{"one": "{} файл", "few": "{} файла", "many": "{} файлов"}
+	arabic := map[string]string{
+		"zero": "zero", "one": "one", "two": "two",
+		"few": "few {}", "many": "many {}", "other": "other {}",
+	}
+
+	tests := []struct {
+		name  string
+		lang  string
+		n     int
+		forms map[string]string
+		want  string
+	}{
+		{"English_one", "en", 1, english, "1 item"},
+		{"English_zero", "en", 0, english, "0 items"},
+		{"English_many", "en-US", 21, english, "21 items"},
+		{"English_negative_one", "en", -1, english, "-1 item"},
+		{"Unknown_language_follows_English", "xx", 1, english, "1 item"},
+		{"French_zero_is_one", "fr", 0, map[string]string{"one": "{} fichier", "other": "{} fichiers"}, "0 fichier"},
+		{"Japanese_other", "ja", 1, map[string]string{"one": "x", "other": "{}個"}, "1個"},
+		{"Russian_one", "ru", 21, russian, "21 файл"},
+		{"Russian_few", "ru", 3, russian, "3 файла"},
+		{"Russian_many", "ru", 5, russian, "5 файлов"},
+		{"Russian_teen_is_many", "ru", 12, russian, "12 файлов"},
+		{"Russian_eleven_is_many", "ru", 111, russian, "111 файлов"},
+		{"Polish_few", "pl", 22, map[string]string{"one": "one", "few": "few", "many": "many"}, "few"},
+		{"Polish_21_is_many", "pl", 21, map[string]string{"one": "one", "few": "few", "many": "many"}, "many"},
+		{"Czech_few", "cs", 4, map[string]string{"few": "few", "other": "other"}, "few"},
+		{"Arabic_zero", "ar", 0, arabic, "zero"},
+		{"Arabic_two", "ar", 2, arabic, "two"},
+		{"Arabic_few", "ar", 103, arabic, "few 103"},
+		{"Arabic_many", "ar", 11, arabic, "many 11"},
+		{"Arabic_other", "ar", 100, arabic, "other 100"},
+		{"Missing_category_uses_other", "ru", 5, map[string]string{"other": "{} files"}, "5 files"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Plural(tc.lang, tc.n, tc.forms); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,422 @@
+package fstr
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ------------------------------------------------------------------
+// Type Registry
+// ------------------------------------------------------------------
+
+// registry holds per-type naming information registered by callers.
+// It is safe for concurrent use.
+var registry = struct {
+	sync.RWMutex
+	flags           map[reflect.Type][]flagName
+	enums           map[reflect.Type]map[int64]string
+	formatters      map[reflect.Type]Formatter
+	ifaceFormatters []ifaceFormatter // in registration order
+}{
+	flags:      make(map[reflect.Type][]flagName),
+	enums:      make(map[reflect.Type]map[int64]string),
+	formatters: make(map[reflect.Type]Formatter),
+}
+
+// formattersRegistered is non-zero once any formatter has been registered,
+// letting the bare "{}" fast path skip the registry until then.
+var formattersRegistered int32
+
+type flagName struct {
+	bit  uint64
+	name string
+}
+
+// RegisterFlags registers names for the bits of a bitmask type, used by the
+// "{:flags}" verb. A name may cover several bits; a name for 0 is used when
+// no bits are set.
+//
+//	fstr.RegisterFlags(reflect.TypeOf(Perm(0)), map[uint64]string{1: "Read", 2: "Write"})
+//	fstr.F("{:flags}", Read|Write) // "Read|Write"
+func RegisterFlags(t reflect.Type, names map[uint64]string) {
+	flags := make([]flagName, 0, len(names))
+	for bit, name := range names {
+		flags = append(flags, flagName{bit: bit, name: name})
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].bit < flags[j].bit })
+
+	registry.Lock()
+	defer registry.Unlock()
+	registry.flags[t] = flags
+}
+
+// formatFlags renders the set bits of val by name, joined with "|". Bits
+// without a name are shown in hex. Unregistered types print as with "{}".
+func formatFlags(val interface{}) string {
+	registry.RLock()
+	flags, ok := registry.flags[reflect.TypeOf(val)]
+	registry.RUnlock()
+
+	bits, isInt := bitsOf(val)
+	if !ok || !isInt {
+		return fmt.Sprint(val)
+	}
+
+	var names []string
+	remaining := bits
+	for _, f := range flags {
+		if f.bit == 0 {
+			if bits == 0 {
+				return f.name
+			}
+			continue
+		}
+		if remaining&f.bit == f.bit {
+			names = append(names, f.name)
+			remaining &^= f.bit
+		}
+	}
+	if remaining != 0 {
+		names = append(names, fmt.Sprintf("0x%x", remaining))
+	}
+	if len(names) == 0 {
+		return "0"
+	}
+	return strings.Join(names, "|")
+}
+
+// RegisterEnum registers names for the values of an integer enum type, so
+// "{}" prints the name even when the type has no String method. Values
+// without a name print as numbers.
+//
+//	fstr.RegisterEnum(reflect.TypeOf(Color(0)), map[int64]string{0: "Red", 1: "Green"})
+//	fstr.F("{}", Color(1)) // "Green"
+func RegisterEnum(t reflect.Type, names map[int64]string) {
+	copied := make(map[int64]string, len(names))
+	for v, name := range names {
+		copied[v] = name
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	registry.enums[t] = copied
+}
+
+// enumName returns the registered name for val, if it has one.
+func enumName(val interface{}) (string, bool) {
+	registry.RLock()
+	names, ok := registry.enums[reflect.TypeOf(val)]
+	registry.RUnlock()
+	if !ok {
+		return "", false
+	}
+
+	rv := reflect.ValueOf(val)
+	var n int64
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n = int64(rv.Uint())
+	default:
+		return "", false
+	}
+	name, ok := names[n]
+	return name, ok
+}
+
+// bitsOf returns the bit pattern of an integer value.
+func bitsOf(val interface{}) (uint64, bool) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return uint64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), true
+	default:
+		return 0, false
+	}
+}
+
+// ------------------------------------------------------------------
+// Formatters
+// ------------------------------------------------------------------
+
+// Formatter renders a value for a placeholder. It receives the placeholder's
+// parsed spec; fill, alignment and width are applied to its result
+// afterwards, so a Formatter only needs to produce the text itself.
+type Formatter func(val interface{}, spec FormatSpecifier) string
+
+type ifaceFormatter struct {
+	iface reflect.Type
+	f     Formatter
+}
+
+// RegisterFormatter registers f for values of exactly type t. It is used for
+// every placeholder except the named verbs ("?", "yaml", ...) and raw "%"
+// directives. A nil f removes the registration.
+//
+//	fstr.RegisterFormatter(reflect.TypeOf(time.Time{}), func(v interface{}, _ fstr.FormatSpecifier) string {
+//		return v.(time.Time).Format(time.Kitchen)
+//	})
+func RegisterFormatter(t reflect.Type, f Formatter) {
+	registry.Lock()
+	defer registry.Unlock()
+	if f == nil {
+		delete(registry.formatters, t)
+		return
+	}
+	registry.formatters[t] = f
+	atomic.StoreInt32(&formattersRegistered, 1)
+}
+
+// RegisterInterfaceFormatter registers f for every type implementing the
+// interface type iface, such as reflect.TypeOf((*Displayable)(nil)).Elem().
+// Formatters registered for a concrete type take precedence; among
+// interfaces, the earliest registered match wins. Registering an interface
+// again replaces its formatter, and a nil f removes it. It panics if iface
+// is not an interface type.
+func RegisterInterfaceFormatter(iface reflect.Type, f Formatter) {
+	if iface.Kind() != reflect.Interface {
+		panic("fstr: RegisterInterfaceFormatter of non-interface type " + iface.String())
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+	for i, existing := range registry.ifaceFormatters {
+		if existing.iface != iface {
+			continue
+		}
+		if f == nil {
+			registry.ifaceFormatters = append(registry.ifaceFormatters[:i:i], registry.ifaceFormatters[i+1:]...)
+		} else {
+			registry.ifaceFormatters[i].f = f
+		}
+		return
+	}
+	if f != nil {
+		registry.ifaceFormatters = append(registry.ifaceFormatters, ifaceFormatter{iface: iface, f: f})
+		atomic.StoreInt32(&formattersRegistered, 1)
+	}
+}
+
+// formatterFor returns the formatter for val and the value to pass it. A
+// formatter registered for val's exact type wins; failing that, one
+// registered for the pointed-to type of a non-nil pointer (called with the
+// dereferenced value) or for the pointer type of a non-pointer (called with
+// a pointer to a copy); and then the first registered interface val's type
+// implements.
+func formatterFor(val interface{}) (Formatter, interface{}, bool) {
+	if atomic.LoadInt32(&formattersRegistered) == 0 {
+		return nil, nil, false
+	}
+	t := reflect.TypeOf(val)
+	if t == nil {
+		return nil, nil, false
+	}
+
+	registry.RLock()
+	defer registry.RUnlock()
+	if f, ok := registry.formatters[t]; ok {
+		return f, val, true
+	}
+	if rv := reflect.ValueOf(val); t.Kind() == reflect.Ptr {
+		if f, ok := registry.formatters[t.Elem()]; ok && !rv.IsNil() {
+			return f, rv.Elem().Interface(), true
+		}
+	} else if f, ok := registry.formatters[reflect.PtrTo(t)]; ok {
+		ptr := reflect.New(t)
+		ptr.Elem().Set(rv)
+		return f, ptr.Interface(), true
+	}
+	for _, entry := range registry.ifaceFormatters {
+		if t.Implements(entry.iface) {
+			return entry.f, val, true
+		}
+	}
+	return nil, nil, false
+}
+
+// TimeFormatter is a Formatter printing a time.Time in RFC 3339 format,
+// with fractional seconds when the spec has a precision, as in "{:.3}".
+//
+//	fstr.RegisterFormatter(reflect.TypeOf(time.Time{}), fstr.TimeFormatter)
+//	fstr.F("at {}", t) // "at 2024-03-01T12:30:00Z"
+func TimeFormatter(val interface{}, spec FormatSpecifier) string {
+	t, ok := val.(time.Time)
+	if !ok {
+		return fmt.Sprint(val)
+	}
+	if !spec.HasPrecision || spec.Precision <= 0 {
+		return t.Format(time.RFC3339)
+	}
+	return t.Format("2006-01-02T15:04:05." + strings.Repeat("0", spec.Precision) + "Z07:00")
+}
+
+// ------------------------------------------------------------------
+// Nested Values
+// ------------------------------------------------------------------
+
+// verbHandled reports whether fs's verb is one fstr knows, or val has a
+// registered formatter, which is given any verb fstr doesn't know.
+func verbHandled(fs FormatSpecifier, val interface{}) bool {
+	if fs.knownVerb() {
+		return true
+	}
+	_, _, ok := formatterFor(val)
+	return ok
+}
+
+// formatNested renders a slice, array or map like fmt's %v, but hands each
+// element, key and nested collection element with a registered formatter to
+// that formatter. It reports false when no element type can have one, so
+// that fmt's output is kept unchanged.
+func formatNested(val interface{}, fs FormatSpecifier) (string, bool) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		if rv.IsNil() {
+			return "", false // collectionMarker's
+		}
+	case reflect.Array:
+	default:
+		return "", false
+	}
+	if hasOwnFormat(rv) || !mayNestFormatted(rv.Type(), 0) {
+		return "", false
+	}
+	// Elements get the spec without its layout; padding applies to the whole.
+	elem := FormatSpecifier{Sign: fs.Sign, Alternate: fs.Alternate, Precision: fs.Precision,
+		HasPrecision: fs.HasPrecision, locale: fs.locale}
+	var sb strings.Builder
+	writeNested(&sb, rv, elem)
+	return sb.String(), true
+}
+
+// mayNestFormatted reports whether a collection of type t can hold a value
+// with a registered formatter. Interface elements might.
+func mayNestFormatted(t reflect.Type, depth int) bool {
+	if depth > maxKVDepth {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return elemMayFormat(t.Elem(), depth)
+	case reflect.Map:
+		return elemMayFormat(t.Key(), depth) || elemMayFormat(t.Elem(), depth)
+	}
+	return false
+}
+
+func elemMayFormat(t reflect.Type, depth int) bool {
+	if t.Kind() == reflect.Interface || hasFormatter(t) {
+		return true
+	}
+	return mayNestFormatted(t, depth+1)
+}
+
+// hasFormatter reports whether formatterFor finds a formatter for values of
+// type t.
+func hasFormatter(t reflect.Type) bool {
+	registry.RLock()
+	defer registry.RUnlock()
+	if _, ok := registry.formatters[t]; ok {
+		return true
+	}
+	if t.Kind() == reflect.Ptr {
+		if _, ok := registry.formatters[t.Elem()]; ok {
+			return true
+		}
+	} else if _, ok := registry.formatters[reflect.PtrTo(t)]; ok {
+		return true
+	}
+	for _, entry := range registry.ifaceFormatters {
+		if t.Implements(entry.iface) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeNested(sb *strings.Builder, rv reflect.Value, fs FormatSpecifier) {
+	if rv.Kind() == reflect.Interface && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || !rv.CanInterface() {
+		fmt.Fprint(sb, rv) // unexported field: fmt can still print it
+		return
+	}
+	val := rv.Interface()
+	if f, arg, ok := formatterFor(val); ok {
+		sb.WriteString(f(arg, fs))
+		return
+	}
+	if hasOwnFormat(rv) {
+		fmt.Fprintf(sb, printfDirective(fs, val), val)
+		return
+	}
+
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		sb.WriteByte('[')
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			writeNested(sb, rv.Index(i), fs)
+		}
+		sb.WriteByte(']')
+	case reflect.Map:
+		if rv.IsNil() {
+			sb.WriteString("map[]")
+			return
+		}
+		sb.WriteString("map[")
+		for i, key := range sortedMapKeys(rv) {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			writeNested(sb, key, fs)
+			sb.WriteByte(':')
+			writeNested(sb, rv.MapIndex(key), fs)
+		}
+		sb.WriteByte(']')
+	case reflect.Ptr:
+		// Inside a collection fmt prints pointers as addresses, even to structs
+		if rv.IsNil() {
+			sb.WriteString("<nil>")
+		} else {
+			fmt.Fprintf(sb, "%p", val)
+		}
+	default:
+		fmt.Fprintf(sb, printfDirective(fs, val), val)
+	}
+}
+
+// sortedMapKeys returns the keys of rv in the order fmt prints them for the
+// common key kinds: numbers by value, strings and others by their text.
+func sortedMapKeys(rv reflect.Value) []reflect.Value {
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch a.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return a.Uint() < b.Uint()
+		case reflect.Float32, reflect.Float64:
+			return a.Float() < b.Float()
+		case reflect.String:
+			return a.String() < b.String()
+		case reflect.Bool:
+			return !a.Bool() && b.Bool()
+		}
+		return fmt.Sprint(a) < fmt.Sprint(b)
+	})
+	return keys
+}
@@ -0,0 +1,304 @@
+package fstr_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazywolf132/fstr"
+)
+
+type Perm uint8
+
+const (
+	PermRead Perm = 1 << iota
+	PermWrite
+	PermExec
+)
+
+func init() {
+	fstr.RegisterFlags(reflect.TypeOf(Perm(0)), map[uint64]string{
+		0:                 "None",
+		uint64(PermRead):  "Read",
+		uint64(PermWrite): "Write",
+		uint64(PermExec):  "Exec",
+	})
+}
+
+func TestFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		val  interface{}
+		want string
+	}{
+		{"Single_flag", PermWrite, "Write"},
+		{"Combined_flags", PermRead | PermWrite, "Read|Write"},
+		{"All_flags", PermRead | PermWrite | PermExec, "Read|Write|Exec"},
+		{"No_flags", Perm(0), "None"},
+		{"Unknown_bit", PermRead | Perm(16), "Read|0x10"},
+		{"Only_unknown_bits", Perm(64), "0x40"},
+		{"Unregistered_type", 3, "3"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf("{:flags}", tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+)
+
+func init() {
+	fstr.RegisterEnum(reflect.TypeOf(Color(0)), map[int64]string{
+		int64(Red):   "Red",
+		int64(Green): "Green",
+	})
+}
+
+func TestEnum(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"Mapped_value", "{}", Green, "Green"},
+		{"Unmapped_value", "{}", Blue, "2"},
+		{"Numeric_verb_ignores_name", "{:d}", Green, "1"},
+		{"Width_applies_to_name", "[{:<6}]", Red, "[Red   ]"},
+		{"Named_field", "{Color}", struct{ Color Color }{Green}, "Green"},
+		{"Unregistered_int", "{}", 1, "1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+type Displayable interface {
+	Display() string
+}
+
+type Celsius float64
+
+func (c Celsius) Display() string { return fmt.Sprintf("%.1f°C", float64(c)) }
+
+type Fahrenheit float64
+
+func (f Fahrenheit) Display() string { return fmt.Sprintf("%.1f°F", float64(f)) }
+
+type Kelvin float64
+
+func (k Kelvin) Display() string { return "unused" }
+
+func TestFormatters(t *testing.T) {
+	displayable := reflect.TypeOf((*Displayable)(nil)).Elem()
+	fstr.RegisterInterfaceFormatter(displayable, func(v interface{}, spec fstr.FormatSpecifier) string {
+		if spec.Verb == "raw" {
+			return fmt.Sprint(reflect.ValueOf(v).Float())
+		}
+		return v.(Displayable).Display()
+	})
+	fstr.RegisterFormatter(reflect.TypeOf(Kelvin(0)), func(v interface{}, _ fstr.FormatSpecifier) string {
+		return fmt.Sprintf("%gK", float64(v.(Kelvin)))
+	})
+	t.Cleanup(func() {
+		fstr.RegisterInterfaceFormatter(displayable, nil)
+		fstr.RegisterFormatter(reflect.TypeOf(Kelvin(0)), nil)
+	})
+
+	tests := []struct {
+		name   string
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"Interface_match", "{}", Celsius(21.5), "21.5°C"},
+		{"Second_type_same_interface", "{}", Fahrenheit(70.7), "70.7°F"},
+		{"Concrete_wins_over_interface", "{}", Kelvin(300), "300K"},
+		{"Spec_is_passed_through", "{:raw}", Celsius(21.5), "21.5"},
+		{"Result_is_padded", "[{:>8}]", Celsius(5), "[   5.0°C]"},
+		{"Named_verb_wins", "{:?}", Celsius(5), "5"},
+		{"Go_directive_wins", "{:%v}", Celsius(5), "5"},
+		{"Unregistered_type", "{}", 2.5, "2.5"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Removed", func(t *testing.T) {
+		fstr.RegisterFormatter(reflect.TypeOf(Kelvin(0)), nil)
+		if got, want := fstr.Sprintf("{}", Kelvin(300)), "unused"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Non_interface_panics", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+		fstr.RegisterInterfaceFormatter(reflect.TypeOf(0), func(interface{}, fstr.FormatSpecifier) string { return "" })
+	})
+}
+
+type Point struct{ X, Y int }
+
+type Size struct{ W, H int }
+
+func TestFormatterPointerFallback(t *testing.T) {
+	fstr.RegisterFormatter(reflect.TypeOf(Point{}), func(v interface{}, _ fstr.FormatSpecifier) string {
+		p := v.(Point)
+		return fmt.Sprintf("(%d, %d)", p.X, p.Y)
+	})
+	fstr.RegisterFormatter(reflect.TypeOf(&Size{}), func(v interface{}, _ fstr.FormatSpecifier) string {
+		s := v.(*Size)
+		return fmt.Sprintf("%dx%d", s.W, s.H)
+	})
+	t.Cleanup(func() {
+		fstr.RegisterFormatter(reflect.TypeOf(Point{}), nil)
+		fstr.RegisterFormatter(reflect.TypeOf(&Size{}), nil)
+	})
+
+	tests := []struct {
+		name string
+		val  interface{}
+		want string
+	}{
+		{"Value_registered_value_given", Point{1, 2}, "(1, 2)"},
+		{"Value_registered_pointer_given", &Point{3, 4}, "(3, 4)"},
+		{"Value_registered_nil_pointer", (*Point)(nil), "<nil>"},
+		{"Pointer_registered_pointer_given", &Size{640, 480}, "640x480"},
+		{"Pointer_registered_value_given", Size{800, 600}, "800x600"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf("{}", tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPointerVerbSkipsFormatters(t *testing.T) {
+	fstr.RegisterFormatter(reflect.TypeOf(Point{}), func(interface{}, fstr.FormatSpecifier) string { return "point" })
+	fstr.RegisterInterfaceFormatter(reflect.TypeOf((*fmt.Stringer)(nil)).Elem(), func(interface{}, fstr.FormatSpecifier) string { return "stringer" })
+	t.Cleanup(func() {
+		fstr.RegisterFormatter(reflect.TypeOf(Point{}), nil)
+		fstr.RegisterInterfaceFormatter(reflect.TypeOf((*fmt.Stringer)(nil)).Elem(), nil)
+	})
+
+	p := &Point{1, 2}
+	if got, want := fstr.Sprintf("{:p}", p), fmt.Sprintf("%p", p); got != want {
+		t.Errorf("type formatter: got %q, want %q", got, want)
+	}
+	if got := fstr.Sprintf("{}", p); got != "point" {
+		t.Errorf("{} should still use the formatter, got %q", got)
+	}
+	buf := &strings.Builder{}
+	if got, want := fstr.Sprintf("{:p}", buf), fmt.Sprintf("%p", buf); got != want {
+		t.Errorf("interface formatter: got %q, want %q", got, want)
+	}
+}
+
+type Event struct {
+	Name string
+	At   time.Time
+}
+
+func TestTimeFormatter(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	fstr.RegisterFormatter(reflect.TypeOf(time.Time{}), fstr.TimeFormatter)
+	t.Cleanup(func() { fstr.RegisterFormatter(reflect.TypeOf(time.Time{}), nil) })
+
+	tests := []struct {
+		name   string
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"Bare_placeholder", "{}", at, "2024-03-01T12:30:00Z"},
+		{"Positional", "{0}", at, "2024-03-01T12:30:00Z"},
+		{"Field", "{At}", Event{At: at}, "2024-03-01T12:30:00Z"},
+		{"Pointer", "{}", &at, "2024-03-01T12:30:00Z"},
+		{"Precision", "{:.3}", at, "2024-03-01T12:30:00.123Z"},
+		{"Padded", "[{:>22}]", at, "[  2024-03-01T12:30:00Z]"},
+		{"Offset", "{}", at.In(time.FixedZone("", 2*3600)), "2024-03-01T14:30:00+02:00"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormattersInCollections(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	later := at.Add(90 * time.Minute)
+	fstr.RegisterFormatter(reflect.TypeOf(time.Time{}), fstr.TimeFormatter)
+	t.Cleanup(func() { fstr.RegisterFormatter(reflect.TypeOf(time.Time{}), nil) })
+
+	tests := []struct {
+		name   string
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"Slice", "{}", []time.Time{at, later}, "[2024-03-01T12:30:00Z 2024-03-01T14:00:00Z]"},
+		{"Precision_per_element", "{:.3}", []time.Time{at}, "[2024-03-01T12:30:00.123Z]"},
+		{"Padding_whole", "[{:>24}]", []time.Time{at}, "[  [2024-03-01T12:30:00Z]]"},
+		{"Array", "{}", [1]time.Time{at}, "[2024-03-01T12:30:00Z]"},
+		{"Pointers", "{}", []*time.Time{&at, nil}, "[2024-03-01T12:30:00Z <nil>]"},
+		{"Nested_slices", "{}", [][]time.Time{{at}, {later}}, "[[2024-03-01T12:30:00Z] [2024-03-01T14:00:00Z]]"},
+		{"Map_values", "{}", map[string]time.Time{"end": later, "start": at},
+			"map[end:2024-03-01T14:00:00Z start:2024-03-01T12:30:00Z]"},
+		{"Map_keys", "{}", map[time.Time]int{at: 1}, "map[2024-03-01T12:30:00Z:1]"},
+		{"Empty_map", "{}", map[string]time.Time{}, "{}"},
+		{"Interface_elements", "{}", []interface{}{1, at, nil}, "[1 2024-03-01T12:30:00Z <nil>]"},
+		{"Field", "{Times}", struct{ Times []time.Time }{[]time.Time{at}}, "[2024-03-01T12:30:00Z]"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	// Collections that can't hold a formatted value still print as fmt does.
+	same := []interface{}{
+		[]int{3, 1}, map[int]string{10: "a", 9: "b"}, [][]string{{"x"}}, []time.Duration{time.Second},
+		[]interface{}{"s", 2.5, []int{1}, map[string]int{"k": 1}}, map[float64]bool{2: true, -1: false},
+		[]*Event{nil},
+	}
+	for _, val := range same {
+		if got, want := fstr.Sprintf("{}", val), fmt.Sprint(val); got != want {
+			t.Errorf("Sprintf(%#v) = %q, want %q", val, got, want)
+		}
+	}
+}
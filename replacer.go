@@ -0,0 +1,58 @@
+package fstr
+
+import "strings"
+
+// ------------------------------------------------------------------
+// Replacer
+// ------------------------------------------------------------------
+
+// Replacer substitutes "{key}" tokens with fixed strings. It is a lighter
+// alternative to Sprintf for simple templating: there are no arguments, specs
+// or field chains, and no reflection. A Replacer is safe for concurrent use.
+type Replacer struct {
+	pairs map[string]string
+}
+
+// NewReplacer returns a Replacer for the given keys and values. The map is
+// copied, so later changes to pairs don't affect the Replacer.
+//
+//	r := fstr.NewReplacer(map[string]string{"host": "db1", "port": "5432"})
+//	r.Replace("{host}:{port}") // "db1:5432"
+func NewReplacer(pairs map[string]string) *Replacer {
+	copied := make(map[string]string, len(pairs))
+	for k, v := range pairs {
+		copied[k] = v
+	}
+	return &Replacer{pairs: copied}
+}
+
+// Replace returns format with every "{key}" whose key is known replaced by
+// its value. Unknown keys are left as they are, and "{{" and "}}" produce
+// literal braces as in Sprintf.
+func (r *Replacer) Replace(format string) string {
+	var sb strings.Builder
+	sb.Grow(len(format))
+
+	for i := 0; i < len(format); {
+		c := format[i]
+		switch {
+		case (c == '{' || c == '}') && i+1 < len(format) && format[i+1] == c:
+			sb.WriteByte(c)
+			i += 2
+		case c == '{':
+			if end := strings.IndexByte(format[i+1:], '}'); end >= 0 {
+				if v, ok := r.pairs[format[i+1:i+1+end]]; ok {
+					sb.WriteString(v)
+					i += end + 2
+					continue
+				}
+			}
+			sb.WriteByte(c)
+			i++
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,42 @@
+package fstr_test
+
+import (
+	"testing"
+
+	"github.com/crazywolf132/fstr"
+)
+
+func TestReplacer(t *testing.T) {
+	pairs := map[string]string{
+		"host": "db1",
+		"port": "5432",
+		"user": "{admin}",
+	}
+	r := fstr.NewReplacer(pairs)
+	pairs["host"] = "changed" // the Replacer keeps its own copy
+
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{"Several_keys", "{user}@{host}:{port}", "{admin}@db1:5432"},
+		{"Repeated_key", "{host}/{host}", "db1/db1"},
+		{"Unknown_key_kept", "{host}:{missing}", "db1:{missing}"},
+		{"Spec_is_not_a_key", "{port:x}", "{port:x}"},
+		{"Escaped_braces", "{{host}} {host}", "{host} db1"},
+		{"Closing_escape", "}}{port}}}", "}5432}"},
+		{"Unclosed", "{host", "{host"},
+		{"Empty_key", "{}", "{}"},
+		{"No_tokens", "plain text", "plain text"},
+		{"Unicode", "héllo {host} ✓", "héllo db1 ✓"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := r.Replace(tc.format); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
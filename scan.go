@@ -0,0 +1,68 @@
+package fstr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ------------------------------------------------------------------
+// Scan
+// ------------------------------------------------------------------
+
+// Scan is the reverse of Sprintf for simple templates: it matches input
+// against format and returns the text captured by each named placeholder.
+//
+//	fstr.Scan("user {name} has id {id}", "user ada has id 7")
+//	// map[string]interface{}{"name": "ada", "id": "7"}
+//
+// Each capture is the shortest text that lets the following literal match,
+// and values are returned as strings. Specs and colors are ignored. Scan
+// reports an error when input doesn't match, when format has a positional
+// or "{}" placeholder, when two placeholders aren't separated by literal
+// text, or when a repeated name would capture different values.
+func Scan(format, input string) (map[string]interface{}, error) {
+	parsed := getParsedFormat(format)
+	segments, placeholders := parsed.segments, parsed.placeholders
+
+	for i, ph := range placeholders {
+		if ph.PositionalIndex != nil || len(ph.FieldChain) == 0 {
+			return nil, fmt.Errorf("placeholder %s in %q has no name to scan into", ph.Raw, format)
+		}
+		if i > 0 && segments[i] == "" {
+			return nil, fmt.Errorf("placeholders %s and %s in %q are ambiguous without text between them",
+				placeholders[i-1].Raw, ph.Raw, format)
+		}
+	}
+
+	if !strings.HasPrefix(input, segments[0]) {
+		return nil, fmt.Errorf("input %q does not start with %q", input, segments[0])
+	}
+	rest := input[len(segments[0]):]
+
+	values := make(map[string]interface{}, len(placeholders))
+	for i, ph := range placeholders {
+		literal := segments[i+1]
+		var end int
+		if i == len(placeholders)-1 {
+			if !strings.HasSuffix(rest, literal) {
+				return nil, fmt.Errorf("input %q does not end with %q", input, literal)
+			}
+			end = len(rest) - len(literal)
+		} else if end = strings.Index(rest, literal); end < 0 {
+			return nil, fmt.Errorf("input %q is missing %q after %s", input, literal, ph.Raw)
+		}
+
+		name := strings.Join(ph.FieldChain, ".")
+		value := rest[:end]
+		if prev, seen := values[name]; seen && prev != value {
+			return nil, fmt.Errorf("placeholder {%s} matched both %q and %q", name, prev, value)
+		}
+		values[name] = value
+		rest = rest[end+len(literal):]
+	}
+
+	if len(placeholders) == 0 && rest != "" {
+		return nil, fmt.Errorf("input %q does not match %q", input, format)
+	}
+	return values, nil
+}
@@ -0,0 +1,103 @@
+package fstr_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/crazywolf132/fstr"
+)
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		want   map[string]interface{}
+	}{
+		{
+			name:   "Name_and_id",
+			format: "user {name} has id {id}",
+			input:  "user ada lovelace has id 7",
+			want:   map[string]interface{}{"name": "ada lovelace", "id": "7"},
+		},
+		{
+			name:   "Round_trip",
+			format: "[{level}] {msg} ({code})",
+			input:  fstr.Sprintf("[{level}] {msg} ({code})", fstr.Fields("level", "warn", "msg", "disk low", "code", 28)),
+			want:   map[string]interface{}{"level": "warn", "msg": "disk low", "code": "28"},
+		},
+		{
+			name:   "Field_chain_name",
+			format: "{user.name}@{host}",
+			input:  "ada@example.com",
+			want:   map[string]interface{}{"user.name": "ada", "host": "example.com"},
+		},
+		{
+			name:   "Escaped_delimiters",
+			format: `{content\:type}; {a\|b}`,
+			input:  "json; x",
+			want:   map[string]interface{}{"content:type": "json", "a|b": "x"},
+		},
+		{
+			name:   "Repeated_name",
+			format: "{x}-{x}",
+			input:  "ab-ab",
+			want:   map[string]interface{}{"x": "ab"},
+		},
+		{
+			name:   "Escaped_braces",
+			format: "{{{key}}}",
+			input:  "{abc}",
+			want:   map[string]interface{}{"key": "abc"},
+		},
+		{
+			name:   "Empty_capture",
+			format: "a={a};",
+			input:  "a=;",
+			want:   map[string]interface{}{"a": ""},
+		},
+		{
+			name:   "Only_literals",
+			format: "ready",
+			input:  "ready",
+			want:   map[string]interface{}{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := fstr.Scan(tc.format, tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+	}{
+		{"Wrong_prefix", "user {name}", "admin ada"},
+		{"Wrong_suffix", "{name}!", "ada?"},
+		{"Missing_separator", "{a}-{b}", "ab"},
+		{"Adjacent_placeholders", "{a}{b}", "ab"},
+		{"Auto_placeholder", "id {}", "id 7"},
+		{"Positional_placeholder", "id {0}", "id 7"},
+		{"Repeated_name_differs", "{x}-{x}", "a-b"},
+		{"Extra_literal_input", "ready", "ready!"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, err := fstr.Scan(tc.format, tc.input); err == nil {
+				t.Errorf("expected an error, got %v", got)
+			}
+		})
+	}
+}
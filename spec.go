@@ -0,0 +1,486 @@
+package fstr
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ------------------------------------------------------------------
+// Format Specifier
+// ------------------------------------------------------------------
+
+// FormatSpecifier is the parsed form of the text after ':' in a placeholder.
+// It follows Rust's grammar:
+//
+//	[[fill]align][sign]['#']['0']['@']['~'][width][',']['.' precision][verb]
+//
+// where align is one of '<', '^' or '>', fill is a single rune or a quoted
+// string such as '..' for multi-rune fills, sign is '+', '-' or "trailing"
+// to print a negative number's '-' after its digits, '@' turns the width
+// into a column on the current output line (for dotted leaders), '~'
+// measures the width in bytes instead of runes, ',' groups
+// the digits of numbers in thousands, and verb is either a printf-style
+// letter ("x", "b", "f", ...), "?" for debug output, or a named verb such
+// as "yaml". A spec starting with '%' is a raw Go fmt directive, such as
+// "%#v", and is passed to fmt unchanged; a spec in angle brackets, such as
+// "<%#x (%b)>", is a fmt template whose every directive formats the same
+// value. The zero value formats like a bare "{}".
+type FormatSpecifier struct {
+	Fill         rune   // padding character; 0 means ' '
+	FillText     string // multi-rune padding, repeated and cut to fit; overrides Fill
+	Align        rune   // '<', '^', '>' or 0 for the default of the value's type
+	Sign         rune   // '+' to always print a sign, '-' or 0 otherwise
+	TrailingSign bool   // "trailing": a negative number's '-' follows its digits, as in 1234-
+	Alternate    bool   // '#': alternate form, e.g. 0x prefix for hex
+	Zero         bool   // '0': pad numbers with zeros after the sign
+	Width        int    // minimum width in runes; 0 when unset
+	Column       bool   // '@': Width is the column the value should reach on the current line
+	ByteWidth    bool   // '~': Width counts bytes rather than runes
+	Group        bool   // ',': group the digits of numbers, as in 1,234,567
+	Precision    int    // digits after the point, or max length for strings
+	HasPrecision bool   // whether Precision was given
+	Verb         string // everything after the numeric parts
+
+	locale *Locale // from SprintfLocale or WithLocale; nil formats numbers like fmt
+}
+
+// Locale returns the locale the placeholder is formatted with, if the call
+// gave one through SprintfLocale or WithLocale.
+func (fs FormatSpecifier) Locale() (Locale, bool) {
+	if fs.locale == nil {
+		return Locale{}, false
+	}
+	return *fs.locale, true
+}
+
+// ParseSpecifier parses the text after ':' in a placeholder, such as ">8.2f"
+// or "yaml", into a FormatSpecifier. It accepts every spec a placeholder
+// does, including ".Name" projections, "keys(a,b)", "wrap40" and raw "%#v"
+// directives, so FormatValue(v, fs) prints what "{:spec}" would. It reports
+// an error for an alignment that isn't at the start of the spec and for a
+// malformed precision.
+func ParseSpecifier(spec string) (FormatSpecifier, error) {
+	if fs := (FormatSpecifier{Verb: spec}); fs.goDirective() || fs.valueTemplate() != "" {
+		return fs, nil
+	}
+
+	var fs FormatSpecifier
+	s := spec
+
+	// [[fill]align]
+	if fill, rest, ok := quotedFill(s); ok {
+		fs.FillText, fs.Align = fill, rune(rest[0])
+		s = rest[1:]
+	} else if r, size := utf8.DecodeRuneInString(s); size > 0 && size < len(s) && isAlign(s[size]) {
+		fs.Fill, fs.Align = r, rune(s[size])
+		s = s[size+1:]
+	} else if s != "" && isAlign(s[0]) {
+		fs.Align = rune(s[0])
+		s = s[1:]
+	}
+
+	// [sign]['#']['0']
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		fs.Sign = rune(s[0])
+		s = s[1:]
+	} else if strings.HasPrefix(s, "trailing") {
+		fs.TrailingSign = true
+		s = s[len("trailing"):]
+	}
+	if s != "" && s[0] == '#' {
+		fs.Alternate = true
+		s = s[1:]
+	}
+	if s != "" && s[0] == '0' {
+		fs.Zero = true
+		s = s[1:]
+	}
+
+	// ['@']['~'][width]
+	if s != "" && s[0] == '@' {
+		fs.Column = true
+		s = s[1:]
+	}
+	if s != "" && s[0] == '~' {
+		fs.ByteWidth = true
+		s = s[1:]
+	}
+	digits := leadingDigits(s)
+	if digits != "" {
+		width, err := parseSpecNumber(digits)
+		if err != nil {
+			return fs, fmt.Errorf("invalid width %q in spec %q", digits, spec)
+		}
+		fs.Width = width
+		s = s[len(digits):]
+	}
+
+	// [',']
+	if s != "" && s[0] == ',' {
+		fs.Group = true
+		s = s[1:]
+	}
+
+	// ['.' precision], or a ".Name" projection verb
+	if proj := (FormatSpecifier{Verb: s}); proj.projection() != nil {
+		fs.Verb = s
+		return fs, nil
+	}
+	if s != "" && s[0] == '.' {
+		digits = leadingDigits(s[1:])
+		if digits == "" && strings.ContainsAny(s, "<^>") {
+			return fs, fmt.Errorf("alignment must come before sign, width and precision in spec %q", spec)
+		}
+		if digits == "" {
+			return fs, fmt.Errorf("missing precision after '.' in spec %q", spec)
+		}
+		precision, err := parseSpecNumber(digits)
+		if err != nil {
+			return fs, fmt.Errorf("invalid precision %q in spec %q", digits, spec)
+		}
+		fs.Precision, fs.HasPrecision = precision, true
+		s = s[1+len(digits):]
+	}
+
+	switch {
+	case strings.HasPrefix(s, "."):
+		return fs, fmt.Errorf("invalid precision %q in spec %q", s, spec)
+	case strings.ContainsAny(s, "<^>"):
+		return fs, fmt.Errorf("alignment must come before sign, width and precision in spec %q", spec)
+	}
+
+	fs.Verb = s
+	return fs, nil
+}
+
+// lenientFormatSpecifier parses spec for the render path. A spec that
+// doesn't fit the grammar is treated as a bare verb, so it degrades to
+// the verb fallback instead of failing the whole format.
+func lenientFormatSpecifier(spec string) FormatSpecifier {
+	fs, err := ParseSpecifier(spec)
+	if err != nil {
+		return FormatSpecifier{Verb: spec}
+	}
+	return fs
+}
+
+// quotedFill splits a leading "'fill'" followed by an alignment off s.
+func quotedFill(s string) (fill, rest string, ok bool) {
+	if !strings.HasPrefix(s, "'") {
+		return "", s, false
+	}
+	end := strings.IndexByte(s[1:], '\'')
+	if end <= 0 || 2+end >= len(s) || !isAlign(s[2+end]) {
+		return "", s, false
+	}
+	return s[1 : 1+end], s[2+end:], true
+}
+
+func isAlign(c byte) bool {
+	return c == '<' || c == '^' || c == '>'
+}
+
+// leadingDigits returns the run of digits and '_' separators at the start of s.
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '_') {
+		i++
+	}
+	return s[:i]
+}
+
+// parseSpecNumber parses a width or precision. Underscores may separate
+// digits, as in Go literals ("1_000"), but not lead, trail or repeat.
+func parseSpecNumber(digits string) (int, error) {
+	if strings.Contains(digits, "_") {
+		if digits[0] == '_' || digits[len(digits)-1] == '_' || strings.Contains(digits, "__") {
+			return 0, fmt.Errorf("misplaced '_' in %q", digits)
+		}
+		digits = strings.ReplaceAll(digits, "_", "")
+	}
+	return strconv.Atoi(digits)
+}
+
+// printfDirective translates the spec into a fmt directive such as "%+08.2f".
+// Width is only part of the directive for sign-aware zero padding; all other
+// padding is applied afterwards by pad.
+func printfDirective(fs FormatSpecifier, val interface{}) string {
+	var sb strings.Builder
+	sb.WriteByte('%')
+	if fs.Sign == '+' {
+		sb.WriteByte('+')
+	}
+	if fs.Alternate {
+		sb.WriteByte('#')
+	}
+	if fs.zeroPad() {
+		sb.WriteByte('0')
+		sb.WriteString(strconv.Itoa(fs.Width))
+	}
+	// As in Rust, a precision without a verb doesn't pad integers: "{:.2}"
+	// prints 7 as "7". "{:.2d}" keeps fmt's minimum of two digits, "07",
+	// and a String method's text is still cut to the precision.
+	if fs.HasPrecision && (fs.Verb != "" || !isInteger(val) || printsAsString(val)) {
+		sb.WriteByte('.')
+		sb.WriteString(strconv.Itoa(fs.Precision))
+	}
+	sb.WriteByte(printfVerb(fs, val))
+	return sb.String()
+}
+
+// printfVerbs are the spec verbs passed through to fmt as-is.
+const printfVerbs = "vxXboOsdeEfFgGcqUtp"
+
+// knownVerb reports whether the spec's verb is empty, a printf-style letter
+// or a registered verb.
+func (fs FormatSpecifier) knownVerb() bool {
+	if fs.Verb == "" || fs.goDirective() || fs.valueTemplate() != "" || fs.projection() != nil || fs.keyList() != nil {
+		return true
+	}
+	if len(fs.Verb) == 1 && strings.Contains(printfVerbs, fs.Verb) {
+		return true
+	}
+	return namedVerb(fs.Verb)
+}
+
+// goDirective reports whether the verb is a raw Go fmt directive such as
+// "%#v", which bypasses fstr's own formatting.
+func (fs FormatSpecifier) goDirective() bool {
+	return strings.HasPrefix(fs.Verb, "%")
+}
+
+// valueTemplate returns the fmt template of a "<%#x (%b)>" verb: the text
+// between the leading '<' and the trailing '>', which must contain a '%'.
+// It returns "" for any other verb.
+func (fs FormatSpecifier) valueTemplate() string {
+	v := fs.Verb
+	if len(v) < 3 || v[0] != '<' || v[len(v)-1] != '>' || !strings.Contains(v, "%") {
+		return ""
+	}
+	return v[1 : len(v)-1]
+}
+
+// integerVerb reports whether the verb formats integers in a base.
+func (fs FormatSpecifier) integerVerb() bool {
+	return len(fs.Verb) == 1 && strings.Contains("dboOxX", fs.Verb)
+}
+
+// plainInteger returns the number held by an integer whose type has a
+// String or Error method, so that integer verbs print "2" for an enum whose
+// String returns "warn", rather than fmt's hex of the text. Other values,
+// and types with their own Format method, are returned unchanged.
+func plainInteger(val interface{}) interface{} {
+	switch val.(type) {
+	case fmt.Formatter:
+		return val
+	case fmt.Stringer, error:
+	default:
+		return val
+	}
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint()
+	}
+	return val
+}
+
+// parseIntString returns the integer s holds, so that integer verbs can
+// format "255" as a number. It reports false unless s is a base-10 integer.
+func parseIntString(s string) (interface{}, bool) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, true
+	}
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return n, true
+	}
+	return nil, false
+}
+
+// projection returns the field chain of a ".Name" or ".Owner.Name" verb,
+// which formats the named field of each element of a slice or array. Each
+// name must look like an identifier: a letter or '_' followed by letters,
+// digits or '_'. It returns nil for any other verb.
+func (fs FormatSpecifier) projection() []string {
+	if len(fs.Verb) < 2 || fs.Verb[0] != '.' {
+		return nil
+	}
+	chain := strings.Split(fs.Verb[1:], ".")
+	for _, name := range chain {
+		if !isIdentifier(name) {
+			return nil
+		}
+	}
+	return chain
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !(unicode.IsLetter(r) || r == '_' || i > 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// keyList returns the keys of a "keys(name,age)" verb, which formats the
+// listed keys of a map or fields of a struct, in that order. It returns nil
+// for any other verb.
+func (fs FormatSpecifier) keyList() []string {
+	if !strings.HasPrefix(fs.Verb, "keys(") || !strings.HasSuffix(fs.Verb, ")") {
+		return nil
+	}
+	keys := []string{}
+	for _, key := range strings.Split(fs.Verb[len("keys("):len(fs.Verb)-1], ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// printfVerb maps the spec's verb onto a single fmt verb letter.
+func printfVerb(fs FormatSpecifier, val interface{}) byte {
+	switch fs.Verb {
+	case "":
+		// Rust's "{:.2}" means two decimals, i.e. %f. And since %+v means
+		// "field names" rather than "sign", a forced sign needs the explicit
+		// numeric verb; otherwise %v is kept so Stringers still apply.
+		switch {
+		case isFloat(val) && fs.HasPrecision:
+			return 'f'
+		case isFloat(val) && fs.Sign == '+':
+			return 'g'
+		case isInteger(val) && fs.Sign == '+':
+			return 'd'
+		}
+		return 'v'
+	default:
+		if len(fs.Verb) == 1 && strings.Contains(printfVerbs, fs.Verb) {
+			return fs.Verb[0]
+		}
+		return 'v'
+	}
+}
+
+// atColumn converts a column spec into a plain width, given the column the
+// value starts at on the current line.
+func (fs FormatSpecifier) atColumn(col int) FormatSpecifier {
+	if fs.Column {
+		fs.Width -= col
+		if fs.Width < 0 {
+			fs.Width = 0
+		}
+		fs.Column = false
+	}
+	return fs
+}
+
+// zeroPad reports whether the width is handled by fmt's '0' flag, which
+// keeps the sign in front of the padding.
+func (fs FormatSpecifier) zeroPad() bool {
+	return fs.Zero && fs.Align == 0 && fs.Width > 0
+}
+
+// pad applies fill, alignment and width to an already formatted value.
+// Numbers align right by default and everything else aligns left.
+func pad(s string, fs FormatSpecifier, val interface{}) string {
+	if fs.Width == 0 || fs.zeroPad() {
+		return s
+	}
+	n := fs.Width - utf8.RuneCountInString(s)
+	if fs.ByteWidth {
+		n = fs.Width - len(s)
+	}
+	if n <= 0 {
+		return s
+	}
+
+	align := fs.Align
+	if align == 0 {
+		align = '<'
+		if isNumber(val) {
+			align = '>'
+		}
+	}
+	switch align {
+	case '>':
+		return fs.fill(n) + s
+	case '^':
+		left := n / 2
+		return fs.fill(left) + s + fs.fill(n-left)
+	default:
+		return s + fs.fill(n)
+	}
+}
+
+// fill returns n runes of padding. A multi-rune FillText is repeated and
+// cut off when it doesn't divide n evenly.
+func (fs FormatSpecifier) fill(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if fs.FillText == "" {
+		if fs.Fill == 0 {
+			return strings.Repeat(" ", n)
+		}
+		return strings.Repeat(string(fs.Fill), n)
+	}
+	pattern := []rune(fs.FillText)
+	out := make([]rune, n)
+	for i := range out {
+		out[i] = pattern[i%len(pattern)]
+	}
+	return string(out)
+}
+
+// printsAsString reports whether fmt's %s prints val as text: strings,
+// byte slices, and values with a String, Error or Format method.
+func printsAsString(val interface{}) bool {
+	switch val.(type) {
+	case fmt.Stringer, error, fmt.Formatter:
+		return true
+	}
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.String:
+		return true
+	case reflect.Slice:
+		return rv.Type().Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+func isFloat(val interface{}) bool {
+	switch reflect.ValueOf(val).Kind() {
+	case reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func isInteger(val interface{}) bool {
+	switch reflect.ValueOf(val).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func isNumber(val interface{}) bool {
+	switch reflect.ValueOf(val).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+package fstr
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFormatSpecifier(t *testing.T) {
+	tests := []struct {
+		spec string
+		want FormatSpecifier
+	}{
+		{"", FormatSpecifier{}},
+		{"x", FormatSpecifier{Verb: "x"}},
+		{"?", FormatSpecifier{Verb: "?"}},
+		{"yaml", FormatSpecifier{Verb: "yaml"}},
+		{"%-8.3q", FormatSpecifier{Verb: "%-8.3q"}},
+		{">8", FormatSpecifier{Align: '>', Width: 8}},
+		{"*^10", FormatSpecifier{Fill: '*', Align: '^', Width: 10}},
+		{"é<3", FormatSpecifier{Fill: 'é', Align: '<', Width: 3}},
+		{".2f", FormatSpecifier{Precision: 2, HasPrecision: true, Verb: "f"}},
+		{"+08.3", FormatSpecifier{Sign: '+', Zero: true, Width: 8, Precision: 3, HasPrecision: true}},
+		{"#x", FormatSpecifier{Alternate: true, Verb: "x"}},
+		{"<<5s", FormatSpecifier{Fill: '<', Align: '<', Width: 5, Verb: "s"}},
+		{"1_0", FormatSpecifier{Width: 10}},
+		{">1_000", FormatSpecifier{Align: '>', Width: 1000}},
+		{".1_0f", FormatSpecifier{Precision: 10, HasPrecision: true, Verb: "f"}},
+		{"'..'>10", FormatSpecifier{FillText: "..", Align: '>', Width: 10}},
+		{".>@20", FormatSpecifier{Fill: '.', Align: '>', Column: true, Width: 20}},
+		{"'-='^7x", FormatSpecifier{FillText: "-=", Align: '^', Width: 7, Verb: "x"}},
+		{",", FormatSpecifier{Group: true}},
+		{"012,.2f", FormatSpecifier{Zero: true, Width: 12, Group: true, Precision: 2, HasPrecision: true, Verb: "f"}},
+		{"trailing,.2f", FormatSpecifier{TrailingSign: true, Group: true, Precision: 2, HasPrecision: true, Verb: "f"}},
+		{">trailing12", FormatSpecifier{Align: '>', TrailingSign: true, Width: 12}},
+		{"~10", FormatSpecifier{ByteWidth: true, Width: 10}},
+		{">~8s", FormatSpecifier{Align: '>', ByteWidth: true, Width: 8, Verb: "s"}},
+		{".Name", FormatSpecifier{Verb: ".Name"}},
+		{".id", FormatSpecifier{Verb: ".id"}},
+		{">20.Owner.Name", FormatSpecifier{Align: '>', Width: 20, Verb: ".Owner.Name"}},
+		{"keys(a,b)", FormatSpecifier{Verb: "keys(a,b)"}},
+		{"wrap40", FormatSpecifier{Verb: "wrap40"}},
+		{"indent2", FormatSpecifier{Verb: "indent2"}},
+		{"<%#x (%b)>", FormatSpecifier{Verb: "<%#x (%b)>"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			got, err := ParseSpecifier(tc.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseSpecifierErrors(t *testing.T) {
+	tests := []string{
+		".",                    // missing precision
+		"..Name",               // empty name in a projection
+		"8.>",                  // alignment after width, not a projection
+		"8.<5",                 // alignment after width, not a projection
+		".Na-me",               // projection name that isn't an identifier
+		".2.3",                 // second precision
+		"08>",                  // alignment after width
+		"+5<",                  // alignment after sign
+		".2^",                  // alignment after precision
+		"99999999999999999999", // width overflows int
+		"1__0",                 // repeated underscore
+		"10_",                  // trailing underscore
+		">_10",                 // leading underscore
+		".2_f",                 // trailing underscore in precision
+		".Name.",               // empty name in a projection
+	}
+
+	for _, spec := range tests {
+		t.Run(spec, func(t *testing.T) {
+			if _, err := ParseSpecifier(spec); err == nil {
+				t.Errorf("ParseSpecifier(%q) returned no error", spec)
+			}
+		})
+	}
+
+	for _, spec := range []string{"8.>", "8.<5"} {
+		if _, err := ParseSpecifier(spec); err == nil || !strings.Contains(err.Error(), "alignment must come before") {
+			t.Errorf("ParseSpecifier(%q) = %v, want a misplaced-alignment error", spec, err)
+		}
+	}
+}
+
+func TestFormatSpecifierRendering(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"String_default_left", "[{:6}]", []interface{}{"ab"}, "[ab    ]"},
+		{"Number_default_right", "[{:6}]", []interface{}{42}, "[    42]"},
+		{"Center_with_fill", "[{:*^7}]", []interface{}{"mid"}, "[**mid**]"},
+		{"Right_align_string", "[{:>5}]", []interface{}{"x"}, "[    x]"},
+		{"Float_precision", "{:.2}", []interface{}{3.14159}, "3.14"},
+		{"Int_precision_ignored", "{:.2}", []interface{}{7}, "7"},
+		{"Int_precision_ignored_padded", "[{:>4.2}]", []interface{}{-7}, "[  -7]"},
+		{"Int_precision_with_verb", "{:.2d}", []interface{}{7}, "07"},
+		{"Float_precision_with_verb", "{:.1f}", []interface{}{2.25}, "2.2"},
+		{"Zero_pad_keeps_sign", "{:08.2f}", []interface{}{-3.14159}, "-0003.14"},
+		{"Forced_sign", "{:+}", []interface{}{5}, "+5"},
+		{"Alternate_hex", "{:#x}", []interface{}{255}, "0xff"},
+		{"String_truncation", "{:.3}", []interface{}{"abcdef"}, "abc"},
+		{"Truncate_then_pad_short", "[{:8.3}]", []interface{}{"ab"}, "[ab      ]"},
+		{"Truncate_then_pad_long", "[{:8.3}]", []interface{}{"abcdef"}, "[abc     ]"},
+		{"Truncate_then_pad_right", "[{:>8.3}]", []interface{}{"abcdef"}, "[     abc]"},
+		{"Truncate_then_pad_center", "[{:*^8.2}]", []interface{}{"xyz"}, "[***xy***]"},
+		{"Truncate_runes_then_pad", "[{:5.3s}]", []interface{}{"héllo"}, "[hél  ]"},
+		{"Width_counts_runes", "[{:4}]", []interface{}{"éé"}, "[éé  ]"},
+		{"Width_counts_runes_right", "[{:>6}]", []interface{}{"café"}, "[  café]"},
+		{"Width_counts_runes_center", "[{:*^7}]", []interface{}{"naïve"}, "[*naïve*]"},
+		{"Width_already_met_by_runes", "[{:3}]", []interface{}{"ñño"}, "[ñño]"},
+		{"Byte_width_ascii_unchanged", "[{:~8}]", []interface{}{"hello"}, "[hello   ]"},
+		{"Byte_width_accented", "[{:~10}]", []interface{}{"héllo"}, "[héllo    ]"},
+		{"Rune_width_accented", "[{:10}]", []interface{}{"héllo"}, "[héllo     ]"},
+		{"Byte_width_east_asian", "[{:>~8}]", []interface{}{"日本"}, "[  日本]"},
+		{"Rune_width_east_asian", "[{:>8}]", []interface{}{"日本"}, "[      日本]"},
+		{"Byte_width_already_met", "[{:~4}]", []interface{}{"日本"}, "[日本]"},
+		{"Byte_width_center", "[{:*^~9}]", []interface{}{"ñññ"}, "[*ñññ**]"},
+		{"Multi_rune_fill_even", "{:'..'>6}", []interface{}{"hi"}, "....hi"},
+		{"Multi_rune_fill_odd", "{:'-='>7}", []interface{}{"hi"}, "-=-=-hi"},
+		{"Multi_rune_fill_center_odd", "{:'ab'^7}", []interface{}{"x"}, "abaxaba"},
+		{"Multi_rune_fill_left", "{:'.:'<6}", []interface{}{"abc"}, "abc.:."},
+		{"Multi_rune_fill_emoji", "{:'🙂✨'>4}", []interface{}{"ok"}, "🙂✨ok"},
+		{"Underscore_width", "[{:1_0}]", []interface{}{"x"}, "[x         ]"},
+		{"Column_leader", "{}{:.>@20}", []interface{}{"Name", "Value"}, "Name...........Value"},
+		{"Column_leader_rows", "{}{:.>@14}\n{}{:.>@14}", []interface{}{"Tea", "1.50", "Espresso", "2.75"}, "Tea.......1.50\nEspresso..2.75"},
+		{"Column_left_pad", "{:<@6}|", []interface{}{"ab"}, "ab    |"},
+		{"Column_already_passed", "{}{:>@3}", []interface{}{"long", "x"}, "longx"},
+		{"Stringer_kept_for_default", "{}", []interface{}{time.Second}, "1s"},
+		{"Positional_right_align", "[{0:>8}]", []interface{}{42}, "[      42]"},
+		{"Positional_zero_pad_precision", "{0:08.2f}", []interface{}{3.14159}, "00003.14"},
+		{"Positional_left_align_number", "[{1:<6}|{0:^5}]", []interface{}{"a", 7}, "[7     |  a  ]"},
+		{"Positional_fill_and_hex", "{0:*>6x}", []interface{}{255}, "****ff"},
+		{"Positional_field_with_spec", "{0.Age:>4}", []interface{}{struct{ Age int }{7}}, "   7"},
+		{"Grouped_int", "{:,}", []interface{}{-1234567}, "-1,234,567"},
+		{"Grouped_float", "{:,.2f}", []interface{}{1234567.891}, "1,234,567.89"},
+		{"Grouped_small", "{:,}", []interface{}{999}, "999"},
+		{"Grouped_hex_unchanged", "{:,x}", []interface{}{1234567}, "12d687"},
+		{"Trailing_negative", "{:trailing,.2f}", []interface{}{-1234.5}, "1,234.50-"},
+		{"Trailing_positive", "{:trailing,.2f}", []interface{}{1234.5}, "1,234.50"},
+		{"Trailing_int", "{:trailing}", []interface{}{-42}, "42-"},
+		{"Trailing_zero_pad", "{:trailing08.1f}", []interface{}{-3.25}, "00003.2-"},
+		{"Trailing_right_aligned", "[{:>trailing10,}]", []interface{}{-1234}, "[    1,234-]"},
+		{"Trailing_ignores_strings", "{:trailing}", []interface{}{"-x"}, "-x"},
+		{"Sign_inside_width_negative", "[{:>8}]", []interface{}{-42}, "[     -42]"},
+		{"Sign_inside_width_positive", "[{:>8}]", []interface{}{42}, "[      42]"},
+		{"Forced_sign_inside_width", "[{:>+8}]", []interface{}{42}, "[     +42]"},
+		{"Forced_sign_negative", "[{:>+8}]", []interface{}{-42}, "[     -42]"},
+		{"Forced_sign_left", "[{:<+8}]", []interface{}{42}, "[+42     ]"},
+		{"Forced_sign_center", "[{:^+8}]", []interface{}{-42}, "[  -42   ]"},
+		{"Plus_as_fill", "[{:+>8}]", []interface{}{-42}, "[+++++-42]"},
+		{"Fill_and_forced_sign", "[{:*>+8}]", []interface{}{42}, "[*****+42]"},
+		{"Forced_sign_float", "[{:>+8.1f}]", []interface{}{-4.25}, "[    -4.2]"},
+		{"Forced_sign_zero_pad", "[{:+08}]", []interface{}{42}, "[+0000042]"},
+		{"Grouped_sign_inside_width", "[{:>+10,}]", []interface{}{4200.5}, "[  +4,200.5]"},
+		{"Grouped_zero_pad", "[{:+010,}]", []interface{}{-4200.5}, "[-004,200.5]"},
+		{"Grouped_zero_pad_wide", "[{:012,.1f}]", []interface{}{-4200.5}, "[-0,004,200.5]"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWidthMatchesFmtForNonASCII(t *testing.T) {
+	for _, word := range []string{"café", "Ångström", "日本", "naïve"} {
+		if got, want := Sprintf("[{:10}]", word), fmt.Sprintf("[%-10s]", word); got != want {
+			t.Errorf("%s: got %q, want %q", word, got, want)
+		}
+		if got, want := Sprintf("[{:>10}]", word), fmt.Sprintf("[%10s]", word); got != want {
+			t.Errorf("%s: got %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestFormatValueMatchesSprintf(t *testing.T) {
+	tests := []struct {
+		spec string
+		val  interface{}
+	}{
+		{"", "plain"},
+		{"x", 255},
+		{">8.2f", 3.14159},
+		{"*^9", "mid"},
+		{"+05", 42},
+		{"?", map[string]string{"k": "a\nb"}},
+		{"yaml", map[string]int{"b": 2, "a": 1}},
+		{".Name", []struct{ Name string }{{"a"}, {"b"}}},
+		{">12.Name", []struct{ Name string }{{"a"}, {"b"}}},
+		{"keys(b,a)", map[string]int{"a": 1, "b": 2}},
+		{"wrap5", "one two three"},
+		{"indent2", "a\nb"},
+		{"%#v", []int{1}},
+		{"<%#x (%b)>", 5},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			fs, err := ParseSpecifier(tc.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := FormatValue(tc.val, fs)
+			want := Sprintf("{:"+tc.spec+"}", tc.val)
+			if got != want {
+				t.Errorf("FormatValue = %q, Sprintf = %q", got, want)
+			}
+		})
+	}
+
+	if got, want := FormatValue(7, FormatSpecifier{}), "7"; got != want {
+		t.Errorf("zero spec: got %q, want %q", got, want)
+	}
+	if got, want := FormatValue("ab", FormatSpecifier{Align: '>', Width: 4}), "  ab"; got != want {
+		t.Errorf("literal spec: got %q, want %q", got, want)
+	}
+}
+
+func TestIntegerVerbsOnStrings(t *testing.T) {
+	type ID string
+
+	tests := []struct {
+		format string
+		arg    interface{}
+		want   string
+	}{
+		{"{:x}", "255", "ff"},
+		{"{:#X}", "255", "0XFF"},
+		{"{:b}", "5", "101"},
+		{"{:o}", "8", "10"},
+		{"{:d}", "123", "123"},
+		{"{:+d}", "-7", "-7"},
+		{"{:08b}", "5", "00000101"},
+		{"[{:>6x}]", "4096", "[  1000]"},
+		{"{:x}", "18446744073709551615", "ffffffffffffffff"},
+		{"{:x}", ID("10"), "a"},
+		{"{:x}", " 16 ", "10"},
+		{"{:x}", "abc", "abc"},
+		{"{:d}", "12.5", "12.5"},
+		{"{:s}", "255", "255"},
+		{"{}", "255", "255"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format+"/"+fmt.Sprint(tc.arg), func(t *testing.T) {
+			if got := Sprintf(tc.format, tc.arg); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
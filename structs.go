@@ -0,0 +1,397 @@
+package fstr
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ------------------------------------------------------------------
+// Struct Flattening
+// ------------------------------------------------------------------
+
+// FormatStruct flattens the exported fields of a struct (or pointer to
+// struct) into a map keyed by field name, suitable as a named-placeholder
+// argument. Fields of embedded structs and non-nil embedded pointers are
+// promoted the same way Go promotes them; an outer field wins over an
+// embedded one with the same name. Non-struct values return nil.
+func FormatStruct(s interface{}) map[string]interface{} {
+	rv, ok := structValue(reflect.ValueOf(s))
+	if !ok {
+		return nil
+	}
+	out := make(map[string]interface{})
+	flattenStruct(rv, out)
+	return out
+}
+
+// KeyValue is a single field produced by FormatStructOrdered.
+type KeyValue struct {
+	Key   string
+	Value interface{}
+}
+
+// FieldOrder selects the order of the fields FormatStructOrdered returns.
+type FieldOrder int
+
+const (
+	// DeclarationOrder lists fields in the order the struct declares them.
+	DeclarationOrder FieldOrder = iota
+	// AlphabeticalOrder sorts fields by name.
+	AlphabeticalOrder
+)
+
+// FormatStructOrdered is like FormatStruct but returns the fields in a
+// fixed order: declaration order by default, or the given order. In
+// declaration order, fields promoted from an embedded struct appear at the
+// position of the embedding field; shadowed fields keep the outer position.
+func FormatStructOrdered(s interface{}, order ...FieldOrder) []KeyValue {
+	rv, ok := structValue(reflect.ValueOf(s))
+	if !ok {
+		return nil
+	}
+	fields := orderedFields(rv)
+	if len(order) > 0 && order[len(order)-1] == AlphabeticalOrder {
+		sortKeyValues(fields)
+	}
+	return fields
+}
+
+func sortKeyValues(kvs []KeyValue) {
+	sort.SliceStable(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+}
+
+// structValue dereferences pointers until it reaches a struct.
+func structValue(rv reflect.Value) (reflect.Value, bool) {
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return rv, false
+		}
+		rv = rv.Elem()
+	}
+	return rv, rv.Kind() == reflect.Struct
+}
+
+func isStructType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+func flattenStruct(rv reflect.Value, out map[string]interface{}) {
+	for _, kv := range orderedFields(rv) {
+		out[kv.Key] = kv.Value
+	}
+}
+
+// orderedFields lists the exported fields of rv in declaration order with
+// embedded fields inlined. Promoted fields follow Go's rules, as
+// reflect.Type.FieldByName does: they never shadow the fields declared on
+// the outer struct, the shallowest wins, and a name promoted from two
+// embeds at the same depth is ambiguous and left out.
+func orderedFields(rv reflect.Value) []KeyValue {
+	// Each slot is either a declared field or the fields of an embed.
+	type slot struct {
+		field    KeyValue
+		promoted []KeyValue
+		index    int
+		embedded bool
+	}
+
+	rt := rv.Type()
+	var slots []slot
+
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+		if sf.Anonymous && isStructType(sf.Type) {
+			if ev, ok := structValue(fv); ok {
+				slots = append(slots, slot{promoted: orderedFields(ev), index: i, embedded: true})
+			}
+			continue // nil embedded pointers contribute nothing
+		}
+		if sf.PkgPath != "" || !fv.CanInterface() {
+			continue // unexported
+		}
+		slots = append(slots, slot{field: KeyValue{Key: sf.Name, Value: fv.Interface()}})
+	}
+
+	out := make([]KeyValue, 0, len(slots))
+	for _, s := range slots {
+		if !s.embedded {
+			out = append(out, s.field)
+			continue
+		}
+		for _, kv := range s.promoted {
+			// Keep kv only if it's the field the name resolves to.
+			if f, ok := rt.FieldByName(kv.Key); ok && f.Index[0] == s.index {
+				out = append(out, kv)
+			}
+		}
+	}
+	return out
+}
+
+// ------------------------------------------------------------------
+// Struct Tags
+// ------------------------------------------------------------------
+
+// fieldTag is the parsed form of a `fstr:"name,fmt=.2f,default=n/a"` struct
+// tag. The name renames the field for named placeholders; fmt is the spec
+// used when the placeholder doesn't give one; default is the literal text
+// printed instead of the field's zero value. Option values may contain
+// commas, as in "fmt=,.2f"; only a comma before "fmt=" or "default=" ends
+// one.
+type fieldTag struct {
+	Name       string
+	Format     string
+	Default    string
+	HasDefault bool
+}
+
+func parseFieldTag(sf reflect.StructField) fieldTag {
+	tag, ok := sf.Tag.Lookup("fstr")
+	if !ok {
+		return fieldTag{}
+	}
+	name, rest, _ := strings.Cut(tag, ",")
+	ft := fieldTag{Name: name}
+	for rest != "" {
+		// A value runs to the next ",key=" of a known option, so it may hold
+		// commas itself, as the grouping flag in "fmt=,.2f" does.
+		end := nextTagOption(rest)
+		key, value, _ := strings.Cut(rest[:end], "=")
+		switch strings.TrimSpace(key) {
+		case "fmt":
+			ft.Format = value
+		case "default":
+			ft.Default, ft.HasDefault = value, true
+		}
+		rest = strings.TrimPrefix(rest[end:], ",")
+	}
+	return ft
+}
+
+// tagOptions are the keys a `fstr` tag option can have.
+var tagOptions = []string{"fmt=", "default="}
+
+// nextTagOption returns the index of the ',' that starts the next known
+// option in opts, or len(opts).
+func nextTagOption(opts string) int {
+	for i := 0; i < len(opts); i++ {
+		if opts[i] != ',' {
+			continue
+		}
+		for _, key := range tagOptions {
+			if strings.HasPrefix(strings.TrimLeft(opts[i+1:], " "), key) {
+				return i
+			}
+		}
+	}
+	return len(opts)
+}
+
+// lookupField finds the field a named placeholder refers to: a field whose
+// `fstr` tag carries that name, otherwise the field with that Go name.
+func lookupField(rt reflect.Type, name string) (fieldPlan, bool) {
+	plan, ok := fieldPlansFor(rt)[name]
+	return plan, ok
+}
+
+// ------------------------------------------------------------------
+// Field Plans
+// ------------------------------------------------------------------
+
+// fieldPlan is a resolved named-placeholder lookup on a struct type.
+type fieldPlan struct {
+	field reflect.StructField
+	tag   fieldTag
+}
+
+// fieldPlans caches, per struct type, the field every placeholder name
+// resolves to. Entries are built once and never modified.
+var fieldPlans sync.Map // reflect.Type -> map[string]fieldPlan
+
+// PrepareType builds the field lookups for a struct type (or pointer to
+// one), and for the struct types reachable through its fields, ahead of
+// time. Formatting works without it; calling it at startup moves the cost
+// of the first Sprintf on a type out of latency-sensitive paths.
+func PrepareType(t reflect.Type) {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	if _, done := fieldPlans.Load(t); done {
+		return
+	}
+	for _, plan := range fieldPlansFor(t) {
+		PrepareType(plan.field.Type)
+	}
+}
+
+func fieldPlansFor(rt reflect.Type) map[string]fieldPlan {
+	if plans, ok := fieldPlans.Load(rt); ok {
+		return plans.(map[string]fieldPlan)
+	}
+	plans, _ := fieldPlans.LoadOrStore(rt, buildFieldPlans(rt))
+	return plans.(map[string]fieldPlan)
+}
+
+// buildFieldPlans resolves every name a placeholder can use on rt. Go names
+// follow FieldByName, so ambiguous promoted names don't resolve. Tag names
+// take precedence and follow the same rules: the shallowest tagged field
+// wins, the first in declaration order among fields of the outer struct,
+// and a tag name used by two embeds at the same depth doesn't resolve.
+func buildFieldPlans(rt reflect.Type) map[string]fieldPlan {
+	visible := reflect.VisibleFields(rt)
+	plans := make(map[string]fieldPlan, len(visible))
+	for _, sf := range visible {
+		if f, ok := rt.FieldByName(sf.Name); ok {
+			plans[sf.Name] = fieldPlan{field: f, tag: parseFieldTag(f)}
+		}
+	}
+	type candidate struct {
+		plan      fieldPlan
+		ambiguous bool
+	}
+	tagged := make(map[string]*candidate)
+	for _, sf := range visible {
+		tag := parseFieldTag(sf)
+		if tag.Name == "" {
+			continue
+		}
+		c := tagged[tag.Name]
+		switch {
+		case c == nil || len(sf.Index) < len(c.plan.field.Index):
+			tagged[tag.Name] = &candidate{plan: fieldPlan{field: sf, tag: tag}}
+		case len(sf.Index) == len(c.plan.field.Index) && len(sf.Index) > 1:
+			c.ambiguous = true
+		}
+	}
+	for name, c := range tagged {
+		if c.ambiguous {
+			delete(plans, name)
+			continue
+		}
+		plans[name] = c.plan
+	}
+	return plans
+}
+
+// ------------------------------------------------------------------
+// Sync Types
+// ------------------------------------------------------------------
+
+// syncHolders caches holdsSync by type.
+var syncHolders sync.Map // reflect.Type -> bool
+
+// syncMarker returns the text printed in place of a value of a type from
+// package sync, such as "<sync.Mutex>".
+func syncMarker(t reflect.Type) (string, bool) {
+	if t.Kind() != reflect.Struct || t.PkgPath() != "sync" {
+		return "", false
+	}
+	return "<" + t.String() + ">", true
+}
+
+// holdsSync reports whether t is a sync type or a struct or array holding
+// one by value.
+func holdsSync(t reflect.Type) bool {
+	if held, ok := syncHolders.Load(t); ok {
+		return held.(bool)
+	}
+	held := false
+	switch t.Kind() {
+	case reflect.Struct:
+		if _, ok := syncMarker(t); ok {
+			held = true
+			break
+		}
+		for i := 0; i < t.NumField() && !held; i++ {
+			held = holdsSync(t.Field(i).Type)
+		}
+	case reflect.Array:
+		held = holdsSync(t.Elem())
+	}
+	syncHolders.Store(t, held)
+	return held
+}
+
+// formatSyncSafe renders a struct (or pointer to struct) holding sync types
+// like fmt's %v, or %+v when plus is set, but prints each sync value as its
+// marker instead of its internal state. It reports false for other values
+// and for values with their own String or Format method.
+func formatSyncSafe(val interface{}, plus bool) (string, bool) {
+	switch val.(type) {
+	case fmt.Formatter, fmt.Stringer, error:
+		return "", false
+	}
+	rv := reflect.ValueOf(val)
+	prefix := ""
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		prefix, rv = "&", rv.Elem()
+	}
+	if !rv.IsValid() || !holdsSync(rv.Type()) {
+		return "", false
+	}
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	writeSyncSafe(&sb, rv, plus)
+	return sb.String(), true
+}
+
+func writeSyncSafe(sb *strings.Builder, rv reflect.Value, plus bool) {
+	if marker, ok := syncMarker(rv.Type()); ok {
+		sb.WriteString(marker)
+		return
+	}
+	if !holdsSync(rv.Type()) || hasOwnFormat(rv) {
+		if plus {
+			fmt.Fprintf(sb, "%+v", rv)
+		} else {
+			fmt.Fprint(sb, rv)
+		}
+		return
+	}
+
+	if rv.Kind() == reflect.Array {
+		sb.WriteByte('[')
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			writeSyncSafe(sb, rv.Index(i), plus)
+		}
+		sb.WriteByte(']')
+		return
+	}
+	sb.WriteByte('{')
+	for i := 0; i < rv.NumField(); i++ {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		if plus {
+			sb.WriteString(rv.Type().Field(i).Name + ":")
+		}
+		writeSyncSafe(sb, rv.Field(i), plus)
+	}
+	sb.WriteByte('}')
+}
+
+// hasOwnFormat reports whether fmt would print rv through its String, Error
+// or Format method.
+func hasOwnFormat(rv reflect.Value) bool {
+	if !rv.CanInterface() {
+		return false // fmt doesn't call methods on unexported fields
+	}
+	switch rv.Interface().(type) {
+	case fmt.Formatter, fmt.Stringer, error:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,274 @@
+package fstr_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/crazywolf132/fstr"
+)
+
+type Base struct {
+	ID      int
+	Created string
+}
+
+type Audit struct {
+	ID     int
+	Author string `fstr:"by"`
+}
+
+type Owner struct {
+	ID   int
+	Name string `fstr:"by"`
+}
+
+// Record embeds two structs that both define ID and the tag name "by", so
+// neither resolves, as with Go's field promotion.
+type Record struct {
+	Audit
+	*Owner
+	Title string
+}
+
+type Account struct {
+	*Base
+	Name    string
+	Created string // shadows Base.Created
+}
+
+func TestFormatStruct(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		want map[string]interface{}
+	}{
+		{
+			name: "Embedded_pointer_contributes_fields",
+			in:   Account{Base: &Base{ID: 7, Created: "base"}, Name: "acme", Created: "outer"},
+			want: map[string]interface{}{"ID": 7, "Name": "acme", "Created": "outer"},
+		},
+		{
+			name: "Nil_embedded_pointer_is_skipped",
+			in:   &Account{Name: "acme"},
+			want: map[string]interface{}{"Name": "acme", "Created": ""},
+		},
+		{
+			name: "Embedded_value",
+			in: struct {
+				Base
+				Extra bool
+			}{Base: Base{ID: 1, Created: "now"}, Extra: true},
+			want: map[string]interface{}{"ID": 1, "Created": "now", "Extra": true},
+		},
+		{
+			name: "Ambiguous_names_are_dropped",
+			in:   Record{Audit: Audit{ID: 1, Author: "ann"}, Owner: &Owner{ID: 2, Name: "bob"}, Title: "t"},
+			want: map[string]interface{}{"Author": "ann", "Name": "bob", "Title": "t"},
+		},
+		{
+			name: "Shallowest_embedded_field_wins",
+			in: struct {
+				Account
+				Base
+			}{Account: Account{Base: &Base{ID: 1}, Name: "deep"}, Base: Base{ID: 2, Created: "shallow"}},
+			want: map[string]interface{}{"ID": 2, "Name": "deep"}, // Created is ambiguous
+		},
+		{
+			name: "Not_a_struct",
+			in:   42,
+			want: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := fstr.FormatStruct(tc.in)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddedPointerFieldAccess(t *testing.T) {
+	acct := Account{Base: &Base{ID: 7}, Name: "acme"}
+	if got, want := fstr.Sprintf("{Name}#{ID}", acct), "acme#7"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := fstr.Sprintf("{ID}", Account{Name: "acme"}), "<invalid field>"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := fstr.Sprintf("{Name}#{ID}", fstr.FormatStruct(acct)), "acme#7"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAmbiguousEmbeddedFields(t *testing.T) {
+	rec := Record{Audit: Audit{ID: 1, Author: "ann"}, Owner: &Owner{ID: 2, Name: "bob"}, Title: "t"}
+	if got, want := fstr.Sprintf("{Title} {ID} {by} {Author} {Name}", rec), "t <invalid field> <invalid field> ann bob"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := fstr.Sprintf("{Audit.ID} {Owner.by}", rec), "1 bob"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatStructOrdered(t *testing.T) {
+	type Inner struct {
+		B int
+		C int
+	}
+	type Outer struct {
+		Z string
+		Inner
+		A string
+		C string // shadows Inner.C but keeps its own position
+	}
+
+	got := fstr.FormatStructOrdered(&Outer{Z: "z", Inner: Inner{B: 1, C: 2}, A: "a", C: "c"})
+	want := []fstr.KeyValue{
+		{Key: "Z", Value: "z"},
+		{Key: "B", Value: 1},
+		{Key: "A", Value: "a"},
+		{Key: "C", Value: "c"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+
+	if got := fstr.FormatStructOrdered("nope"); got != nil {
+		t.Errorf("got %#v, want nil", got)
+	}
+
+	outer := Outer{Z: "z", Inner: Inner{B: 1, C: 2}, A: "a", C: "c"}
+	if got := fstr.FormatStructOrdered(outer, fstr.DeclarationOrder); !reflect.DeepEqual(got, want) {
+		t.Errorf("declaration order: got %#v, want %#v", got, want)
+	}
+	sorted := []fstr.KeyValue{
+		{Key: "A", Value: "a"},
+		{Key: "B", Value: 1},
+		{Key: "C", Value: "c"},
+		{Key: "Z", Value: "z"},
+	}
+	if got := fstr.FormatStructOrdered(outer, fstr.AlphabeticalOrder); !reflect.DeepEqual(got, sorted) {
+		t.Errorf("alphabetical order: got %#v, want %#v", got, sorted)
+	}
+}
+
+type Ledger struct {
+	Owner   string  `fstr:"owner"`
+	Balance float64 `fstr:"balance,fmt=.2f"`
+	Rate    float64 `fstr:",fmt=.1f"`
+	Total   float64 `fstr:"total,fmt=,.2f"`
+	Limit   int     `fstr:"limit,fmt=>10,,default=none"`
+}
+
+func TestFieldTagFormat(t *testing.T) {
+	l := Ledger{Owner: "ann", Balance: 1234.5, Rate: 0.25, Total: 1234.5, Limit: 25000}
+
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{"Tag_name_and_default_spec", "{owner}: {balance}", "ann: 1234.50"},
+		{"Explicit_spec_overrides_tag", "{balance:.0f}", "1234"},
+		{"Go_name_still_resolves", "{Balance}", "1234.50"},
+		{"Default_spec_without_rename", "{Rate}", "0.2"},
+		{"Positional_field_chain", "{0.balance}", "1234.50"},
+		{"Grouped_spec", "{total}", "1,234.50"},
+		{"Grouped_spec_before_default", "[{limit}]", "[    25,000]"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, l); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+type Ticket struct {
+	ID       int     `fstr:"id"`
+	Status   string  `fstr:"status,default=unknown"`
+	Priority int     `fstr:",default=none"`
+	Score    float64 `fstr:"score,fmt=.1f,default=n/a"`
+	*Base    `fstr:",default=-"`
+	Note     string `fstr:",default=a, b"`
+	Total    int    `fstr:",fmt=,,default=n/a, none"`
+}
+
+func TestFieldTagDefault(t *testing.T) {
+	tests := []struct {
+		name   string
+		ticket Ticket
+		format string
+		want   string
+	}{
+		{"Zero_value_uses_default", Ticket{ID: 1}, "{id}: {status}", "1: unknown"},
+		{"Set_value_wins", Ticket{Status: "open"}, "{status}", "open"},
+		{"Default_without_rename", Ticket{}, "{Priority}", "none"},
+		{"Default_ignores_tag_spec", Ticket{}, "{score}", "n/a"},
+		{"Tag_spec_applies_to_value", Ticket{Score: 2.25}, "{score}", "2.2"},
+		{"Placeholder_spec_pads_default", Ticket{}, "[{status:>9}]", "[  unknown]"},
+		{"Untagged_zero_value", Ticket{}, "{id}", "0"},
+		{"Nil_embedded_pointer", Ticket{}, "{Base}", "-"},
+		{"Default_with_commas", Ticket{}, "{Note}", "a, b"},
+		{"Grouped_spec_with_default", Ticket{Total: 1234567}, "{Total}", "1,234,567"},
+		{"Grouped_spec_default_used", Ticket{}, "{Total}", "n/a, none"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.ticket); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+type Counter struct {
+	mu    sync.Mutex
+	Count int
+}
+
+type Registry struct {
+	Name    string
+	lock    sync.RWMutex
+	Counter Counter
+	Waits   [2]sync.WaitGroup
+	Next    *Counter
+}
+
+func TestSyncTypes(t *testing.T) {
+	counter := &Counter{Count: 5}
+	registry := &Registry{Name: "jobs", Counter: Counter{Count: 2}}
+
+	tests := []struct {
+		name   string
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"Mutex_field", "{}", counter, "&{<sync.Mutex> 5}"},
+		{"Debug_names_fields", "{:?}", counter, "&{mu:<sync.Mutex> Count:5}"},
+		{"Bare_mutex", "{}", &sync.Mutex{}, "&<sync.Mutex>"},
+		{"Nested", "{}", registry, "&{jobs <sync.RWMutex> {<sync.Mutex> 2} [<sync.WaitGroup> <sync.WaitGroup>] <nil>}"},
+		{"Field_chain", "{Counter.Count}", registry, "2"},
+		{"No_sync_types", "{}", &Base{ID: 1}, "&{1 }"},
+		{"YAML", "{:yaml}", &struct {
+			Mu sync.Mutex
+			N  int
+		}{N: 1}, "Mu: <sync.Mutex>\nN: 1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
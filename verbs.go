@@ -0,0 +1,816 @@
+package fstr
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ------------------------------------------------------------------
+// Verbs
+// ------------------------------------------------------------------
+
+// verbFunc renders a whole value for a named verb such as "{:yaml}".
+type verbFunc func(val interface{}) string
+
+// verbs maps a spec name to the verb that renders it. A spec that isn't
+// listed here falls through to the printf-style specs.
+var verbs = map[string]verbFunc{
+	"?":        formatDebug,
+	"yaml":     formatYAML,
+	"csv":      formatCSV,
+	"flags":    formatFlags,
+	"errchain": formatErrChain,
+	"errstack": formatErrStack,
+	"y":        formatYesNo("yes", "no"),
+	"Y":        formatYesNo("YES", "NO"),
+	"title":    formatTitle,
+	"bytes":    formatBytes,
+}
+
+// wholeVerbs lists the verbs whose output a precision doesn't cut, because
+// it is structured or multi-line. Other verbs in the verbs map are cut to
+// the precision like a string, as in "{:.5title}".
+var wholeVerbs = map[string]bool{
+	"?":        true,
+	"yaml":     true,
+	"csv":      true,
+	"errstack": true,
+	"bytes":    true,
+}
+
+// Verbs that format nested values through FormatValue would make the
+// verbs map refer to itself, so they are added at init.
+func init() {
+	specVerbs["kvlines"] = formatKVLines
+}
+
+// specVerbs maps the name of a verb that also reads the placeholder's spec,
+// such as the precision in "{:.2compact}", to the verb that renders it.
+var specVerbs = map[string]func(val interface{}, fs FormatSpecifier) string{
+	"compact": formatCompact,
+}
+
+// namedVerb reports whether verb is one of fstr's named verbs rather than
+// a printf-style letter.
+func namedVerb(verb string) bool {
+	if _, ok := verbs[verb]; ok {
+		return true
+	}
+	if _, ok := specVerbs[verb]; ok {
+		return true
+	}
+	_, _, ok := sizedVerb(verb)
+	return ok
+}
+
+// sizedVerbFunc renders a value for a verb that takes a size, such as the 40
+// in "{:wrap40}". size is -1 when the spec gives none.
+type sizedVerbFunc func(val interface{}, size int) string
+
+// sizedVerb splits a verb such as "wrap40" into the sized verb it names and
+// its size.
+func sizedVerb(verb string) (sizedVerbFunc, int, bool) {
+	name := strings.TrimRight(verb, "0123456789")
+	var f sizedVerbFunc
+	switch name {
+	case "wrap":
+		f = formatWrap
+	case "indent":
+		f = formatIndent
+	default:
+		return nil, 0, false
+	}
+	if name == verb {
+		return f, -1, true
+	}
+	size, err := strconv.Atoi(verb[len(name):])
+	if err != nil {
+		return nil, 0, false
+	}
+	return f, size, true
+}
+
+// ------------------------------------------------------------------
+// Debug
+// ------------------------------------------------------------------
+
+// formatDebug renders val like %+v, escaping control characters so strings
+// with embedded newlines or tabs keep the output on a single line.
+func formatDebug(val interface{}) string {
+	return escapeControl(fmt.Sprintf("%+v", val))
+}
+
+func escapeControl(s string) string {
+	if strings.IndexFunc(s, isControl) < 0 {
+		return s
+	}
+	var sb strings.Builder
+	for _, r := range s {
+		if !isControl(r) {
+			sb.WriteRune(r)
+			continue
+		}
+		q := strconv.QuoteRune(r) // e.g. '\n' or '\x01'
+		sb.WriteString(q[1 : len(q)-1])
+	}
+	return sb.String()
+}
+
+func isControl(r rune) bool {
+	return r < ' ' || r == 0x7f
+}
+
+// ------------------------------------------------------------------
+// Value Templates
+// ------------------------------------------------------------------
+
+// formatValueTemplate runs a "<%#x (%b)>" template, handing val to every
+// directive in it; "%%" is a literal percent sign.
+func formatValueTemplate(tmpl string, val interface{}) string {
+	n := 0
+	for i := 0; i < len(tmpl); i++ {
+		if tmpl[i] != '%' {
+			continue
+		}
+		if i+1 < len(tmpl) && tmpl[i+1] == '%' {
+			i++
+			continue
+		}
+		n++
+	}
+	args := make([]interface{}, n)
+	for i := range args {
+		args[i] = val
+	}
+	return fmt.Sprintf(tmpl, args...)
+}
+
+// ------------------------------------------------------------------
+// Projection
+// ------------------------------------------------------------------
+
+// formatProjection renders the field chain of each element of a slice or
+// array as "[a, b]". Elements without the field, and values that aren't
+// slices or arrays, format as with "{}".
+func formatProjection(val interface{}, chain []string) string {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return formatValue(val, FormatSpecifier{})
+	}
+
+	parts := make([]string, rv.Len())
+	for i := range parts {
+		elem := rv.Index(i).Interface()
+		field, _ := getFieldChainValue(elem, chain)
+		if field == invalidField {
+			field = elem
+		}
+		parts[i] = formatValue(field, FormatSpecifier{})
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// formatKeys renders the given keys of a map, or fields of a struct, as
+// "name: Alice, age: 30". A key may be a field chain such as "Owner.Name".
+// Absent keys are skipped, and values that are neither maps nor structs
+// format as with "{}".
+func formatKeys(val interface{}, keys []string) string {
+	rv := reflect.Indirect(reflect.ValueOf(val))
+	if rv.Kind() != reflect.Map && rv.Kind() != reflect.Struct {
+		return formatValue(val, FormatSpecifier{})
+	}
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		field, _ := getFieldChainValue(val, splitFieldChain(key))
+		if _, missing := field.(missingValue); missing {
+			continue
+		}
+		parts = append(parts, key+": "+formatValue(field, FormatSpecifier{}))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ------------------------------------------------------------------
+// Booleans
+// ------------------------------------------------------------------
+
+// formatYesNo returns a verb printing booleans (including named bool types)
+// as yes or no. Non-booleans format as with "{}".
+func formatYesNo(yes, no string) verbFunc {
+	return func(val interface{}) string {
+		rv := reflect.ValueOf(val)
+		if rv.Kind() != reflect.Bool {
+			return fmt.Sprint(val)
+		}
+		if rv.Bool() {
+			return yes
+		}
+		return no
+	}
+}
+
+// ------------------------------------------------------------------
+// Key-Value Lines
+// ------------------------------------------------------------------
+
+// maxKVDepth bounds how deeply formatKVLines expands nested values, so
+// cyclic pointers terminate.
+const maxKVDepth = 16
+
+// formatKVLines renders a struct or map as "key: value" lines, one per
+// line, which keeps diffs of test output readable. Keys are sorted, except
+// that "{:#kvlines}" keeps struct fields in declaration order. Nested
+// structs and maps are expanded below their key, indented two spaces per
+// level. Values with a String method or a registered formatter, and
+// everything other than structs and maps, print as with "{}", with control
+// characters escaped so each entry stays on its line.
+func formatKVLines(val interface{}, fs FormatSpecifier) string {
+	order := AlphabeticalOrder
+	if fs.Alternate {
+		order = DeclarationOrder
+	}
+	entries, ok := kvEntries(reflect.ValueOf(val), order)
+	if !ok {
+		return FormatValue(val, FormatSpecifier{})
+	}
+	var lines []string
+	appendKVLines(&lines, entries, order, "", 0)
+	return strings.Join(lines, "\n")
+}
+
+func appendKVLines(lines *[]string, entries []KeyValue, order FieldOrder, indent string, depth int) {
+	for _, kv := range entries {
+		nested, ok := kvEntries(reflect.ValueOf(kv.Value), order)
+		switch {
+		case ok && len(nested) == 0:
+			*lines = append(*lines, indent+kv.Key+": {}")
+		case ok && depth < maxKVDepth:
+			*lines = append(*lines, indent+kv.Key+":")
+			appendKVLines(lines, nested, order, indent+"  ", depth+1)
+		default:
+			*lines = append(*lines, indent+kv.Key+": "+escapeControl(FormatValue(kv.Value, FormatSpecifier{})))
+		}
+	}
+}
+
+// kvEntries returns the entries of a struct or map, through any pointers.
+// Map keys are always sorted; struct fields follow order. It reports false
+// for other values, nil pointers, sync types, and values that print through
+// their own method or a formatter.
+func kvEntries(rv reflect.Value, order FieldOrder) ([]KeyValue, bool) {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() || hasOwnFormat(rv) {
+		return nil, false
+	}
+	if _, _, ok := formatterFor(rv.Interface()); ok {
+		return nil, false
+	}
+
+	var entries []KeyValue
+	switch rv.Kind() {
+	case reflect.Struct:
+		if _, ok := syncMarker(rv.Type()); ok {
+			return nil, false
+		}
+		entries = orderedFields(rv)
+		if order == AlphabeticalOrder {
+			sortKeyValues(entries)
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			entries = append(entries, KeyValue{Key: fmt.Sprint(iter.Key().Interface()), Value: iter.Value().Interface()})
+		}
+		sortKeyValues(entries)
+	default:
+		return nil, false
+	}
+	return entries, true
+}
+
+// ------------------------------------------------------------------
+// Raw Bytes
+// ------------------------------------------------------------------
+
+// formatBytes renders a []byte (or any slice of a byte type) or a string
+// as its bytes, unchanged: invalid UTF-8 and control bytes are neither
+// validated nor escaped, so the writer functions can emit binary framing.
+// Other values format as with "{}".
+func formatBytes(val interface{}) string {
+	switch v := val.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+		return string(rv.Bytes())
+	}
+	return fmt.Sprint(val)
+}
+
+// ------------------------------------------------------------------
+// Compact Numbers
+// ------------------------------------------------------------------
+
+// compactUnits are the suffixes formatCompact uses for powers of 1000.
+var compactUnits = []string{"", "k", "M", "B", "T"}
+
+// formatCompact renders a number as a short count such as "12.3k" or
+// "1.2M", with one decimal by default or fs.Precision decimals when given,
+// and the locale's decimal separator if the call has one.
+// Without a precision, trailing zeros are dropped ("1k", not "1.0k"), and
+// whole numbers under 1000 print as they are. Non-numbers format as with
+// "{}".
+func formatCompact(val interface{}, fs FormatSpecifier) string {
+	n, ok := numberOf(reflect.ValueOf(val))
+	if !ok || math.IsInf(n, 0) || math.IsNaN(n) {
+		return fmt.Sprint(val)
+	}
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	if n < 1000 && n == math.Trunc(n) {
+		return sign + strconv.FormatFloat(n, 'f', -1, 64)
+	}
+
+	prec := 1
+	if fs.HasPrecision {
+		prec = fs.Precision
+	}
+	unit := 0
+	for n >= 1000 && unit < len(compactUnits)-1 {
+		n /= 1000
+		unit++
+	}
+	s := strconv.FormatFloat(n, 'f', prec, 64)
+	if rounded, _ := strconv.ParseFloat(s, 64); rounded >= 1000 && unit < len(compactUnits)-1 {
+		unit++ // 999999 rounds to 1000.0k: print 1M instead
+		s = strconv.FormatFloat(n/1000, 'f', prec, 64)
+	}
+	if !fs.HasPrecision && strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if fs.locale != nil && fs.locale.Decimal != "" {
+		s = strings.Replace(s, ".", fs.locale.Decimal, 1)
+	}
+	return sign + s + compactUnits[unit]
+}
+
+// ------------------------------------------------------------------
+// Title Case
+// ------------------------------------------------------------------
+
+// formatTitle capitalizes the first letter of each word in the text of val
+// and lowercases the rest. Words are split on anything but letters, digits
+// and apostrophes, so "jean-luc" becomes "Jean-Luc" and "don't" becomes
+// "Don't"; a single letter before an apostrophe starts a name, as in
+// "O'Brien" and "D'Angelo".
+func formatTitle(val interface{}) string {
+	runes := []rune(fmt.Sprint(val))
+	var sb strings.Builder
+	sb.Grow(len(runes))
+	start := true // the next letter begins a word
+	for i, r := range runes {
+		switch {
+		case unicode.IsLetter(r) && start:
+			sb.WriteRune(unicode.ToTitle(r))
+			start = false
+		case unicode.IsLetter(r):
+			sb.WriteRune(unicode.ToLower(r))
+		case unicode.IsDigit(r) || unicode.IsMark(r):
+			sb.WriteRune(r)
+			start = false
+		case (r == '\'' || r == '’') && !start:
+			sb.WriteRune(r)
+			start = isElision(runes, i)
+		default:
+			sb.WriteRune(r)
+			start = true
+		}
+	}
+	return sb.String()
+}
+
+// isElision reports whether the apostrophe at runes[i] follows a lone
+// letter and precedes a longer word, as in "o'brien" but not "i'm".
+func isElision(runes []rune, i int) bool {
+	if i == 0 || !unicode.IsLetter(runes[i-1]) || (i >= 2 && unicode.IsLetter(runes[i-2])) {
+		return false
+	}
+	return i+2 < len(runes) && unicode.IsLetter(runes[i+1]) && unicode.IsLetter(runes[i+2])
+}
+
+// ------------------------------------------------------------------
+// Text Layout
+// ------------------------------------------------------------------
+
+// formatWrap breaks the text of val into lines of at most width runes (80
+// by default), breaking only between words. A word longer than width gets a
+// line of its own. Existing line breaks are kept, and spaces between words
+// on a line collapse to one.
+func formatWrap(val interface{}, width int) string {
+	if width < 0 {
+		width = 80
+	}
+	lines := strings.Split(FormatValue(val, FormatSpecifier{}), "\n")
+	var out []string
+	for _, line := range lines {
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			out = append(out, "")
+			continue
+		}
+		current, length := words[0], utf8.RuneCountInString(words[0])
+		for _, word := range words[1:] {
+			n := utf8.RuneCountInString(word)
+			if length+1+n > width {
+				out = append(out, current)
+				current, length = word, n
+				continue
+			}
+			current += " " + word
+			length += 1 + n
+		}
+		out = append(out, current)
+	}
+	return strings.Join(out, "\n")
+}
+
+// formatIndent prefixes every non-empty line of the text of val with size
+// spaces (4 by default).
+func formatIndent(val interface{}, size int) string {
+	if size < 0 {
+		size = 4
+	}
+	prefix := strings.Repeat(" ", size)
+	lines := strings.Split(FormatValue(val, FormatSpecifier{}), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ------------------------------------------------------------------
+// Error Chains
+// ------------------------------------------------------------------
+
+// formatErrChain renders an error and everything it wraps as
+// "outer: middle: inner", even when a wrapper's Error() doesn't mention the
+// error it wraps. Errors wrapping several errors (errors.Join) list their
+// branches separated by "; ". Non-errors format as with "{}".
+func formatErrChain(val interface{}) string {
+	err, ok := val.(error)
+	if !ok {
+		return fmt.Sprint(val)
+	}
+	return errChain(err)
+}
+
+func errChain(err error) string {
+	switch u := err.(type) {
+	case interface{ Unwrap() []error }:
+		children := u.Unwrap()
+		messages := make([]string, len(children))
+		chains := make([]string, len(children))
+		for i, child := range children {
+			messages[i] = child.Error()
+			chains[i] = errChain(child)
+		}
+		if err.Error() != strings.Join(messages, "\n") {
+			return err.Error() // the children are already part of the message
+		}
+		return strings.Join(chains, "; ")
+	default:
+		next := errors.Unwrap(err)
+		if next == nil {
+			return err.Error()
+		}
+		own := err.Error()
+		if own == next.Error() {
+			return errChain(next)
+		}
+		own = strings.TrimSuffix(own, ": "+next.Error())
+		return own + ": " + errChain(next)
+	}
+}
+
+// formatErrStack renders an error's message followed by the stack trace of
+// the innermost error in its chain that carries one, one frame per line.
+// A stack is anything returned by a StackTrace() method, as provided by
+// github.com/pkg/errors; slices are printed frame by frame with %+v. Errors
+// without a stack print their message, and non-errors format as with "{}".
+func formatErrStack(val interface{}) string {
+	err, ok := val.(error)
+	if !ok {
+		return fmt.Sprint(val)
+	}
+
+	var stack reflect.Value
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if s, ok := stackTrace(e); ok {
+			stack = s
+		}
+	}
+	if !stack.IsValid() {
+		return err.Error()
+	}
+
+	var sb strings.Builder
+	sb.WriteString(err.Error())
+	if stack.Kind() != reflect.Slice {
+		sb.WriteString("\n" + fmt.Sprintf("%+v", stack.Interface()))
+		return sb.String()
+	}
+	for i := 0; i < stack.Len(); i++ {
+		sb.WriteString("\n" + fmt.Sprintf("%+v", stack.Index(i).Interface()))
+	}
+	return sb.String()
+}
+
+// stackTrace calls err's StackTrace method, if it has one taking no
+// arguments and returning a single value.
+func stackTrace(err error) (reflect.Value, bool) {
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return reflect.Value{}, false
+	}
+	return m.Call(nil)[0], true
+}
+
+// ------------------------------------------------------------------
+// CSV
+// ------------------------------------------------------------------
+
+// formatCSV renders a slice or array as a single CSV record, quoting fields
+// the way encoding/csv does. Anything else yields "<invalid csv>".
+func formatCSV(val interface{}) string {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "<invalid csv>"
+	}
+	record := make([]string, rv.Len())
+	for i := range record {
+		record[i] = fmt.Sprint(rv.Index(i).Interface())
+	}
+
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	if err := w.Write(record); err != nil {
+		return "<invalid csv>"
+	}
+	w.Flush()
+	if w.Error() != nil {
+		return "<invalid csv>"
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// ------------------------------------------------------------------
+// YAML
+// ------------------------------------------------------------------
+
+// formatYAML renders maps, slices, structs and scalars as a YAML fragment.
+// Map keys are sorted so the output is deterministic. Values that have no
+// YAML representation (channels, funcs, complex numbers) or that contain
+// themselves, as a list node whose Next points back to it does, yield
+// "<invalid yaml>".
+func formatYAML(val interface{}) string {
+	scalar, block, ok := yamlNode(reflect.ValueOf(val), yamlPath{})
+	if !ok {
+		return "<invalid yaml>"
+	}
+	if block == nil {
+		return scalar
+	}
+	return strings.Join(block, "\n")
+}
+
+// yamlPath holds the pointers, maps and slices being rendered around the
+// current node; meeting one again means the value contains itself. Entries
+// are keyed by type too, since a struct and its first field share an address.
+type yamlPath map[yamlRef]bool
+
+type yamlRef struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// enter adds rv to the path, reporting false on a cycle. The returned func
+// removes it again.
+func (p yamlPath) enter(rv reflect.Value) (func(), bool) {
+	ref := yamlRef{rv.Pointer(), rv.Type()}
+	if p[ref] {
+		return nil, false
+	}
+	p[ref] = true
+	return func() { delete(p, ref) }, true
+}
+
+// yamlNode returns either a scalar (for plain values and empty collections)
+// or the lines of a block collection.
+func yamlNode(rv reflect.Value, path yamlPath) (string, []string, bool) {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return "null", nil, true
+		}
+		if rv.Kind() == reflect.Ptr {
+			leave, ok := path.enter(rv)
+			if !ok {
+				return "", nil, false
+			}
+			defer leave()
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return "null", nil, true
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), nil, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), nil, true
+	case reflect.Float32, reflect.Float64:
+		return yamlFloat(rv.Float()), nil, true
+	case reflect.String:
+		return yamlString(rv.String()), nil, true
+	case reflect.Map, reflect.Slice:
+		if rv.IsNil() || rv.Len() == 0 {
+			break // no elements to recurse into
+		}
+		leave, ok := path.enter(rv)
+		if !ok {
+			return "", nil, false
+		}
+		defer leave()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		return yamlMap(rv, path)
+	case reflect.Slice, reflect.Array:
+		return yamlSequence(rv, path)
+	case reflect.Struct:
+		if marker, ok := syncMarker(rv.Type()); ok {
+			return yamlString(marker), nil, true
+		}
+		return yamlStruct(rv, path)
+	default:
+		return "", nil, false
+	}
+}
+
+// yamlMap writes every entry, sorted by key. Keys are written as YAML
+// scalars, so the int 1 and the string "1" stay apart as 1 and "1".
+func yamlMap(rv reflect.Value, path yamlPath) (string, []string, bool) {
+	if rv.Len() == 0 {
+		return "{}", nil, true
+	}
+	type entry struct {
+		sortKey, key string
+		val          reflect.Value
+	}
+	entries := make([]entry, 0, rv.Len())
+	for _, k := range rv.MapKeys() {
+		key, block, ok := yamlNode(k, path)
+		if !ok || block != nil {
+			key = yamlString(fmt.Sprint(k.Interface()))
+		}
+		entries = append(entries, entry{fmt.Sprint(k.Interface()), key, rv.MapIndex(k)})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].sortKey != entries[j].sortKey {
+			return entries[i].sortKey < entries[j].sortKey
+		}
+		return entries[i].key < entries[j].key
+	})
+
+	keys := make([]string, len(entries))
+	vals := make([]reflect.Value, len(entries))
+	for i, e := range entries {
+		keys[i], vals[i] = e.key, e.val
+	}
+	return yamlMapping(keys, vals, path)
+}
+
+func yamlStruct(rv reflect.Value, path yamlPath) (string, []string, bool) {
+	rt := rv.Type()
+	var keys []string
+	var vals []reflect.Value
+	for i := 0; i < rt.NumField(); i++ {
+		if rt.Field(i).PkgPath != "" {
+			continue // unexported
+		}
+		keys = append(keys, yamlString(rt.Field(i).Name))
+		vals = append(vals, rv.Field(i))
+	}
+	if len(keys) == 0 {
+		return "{}", nil, true
+	}
+	return yamlMapping(keys, vals, path)
+}
+
+// yamlMapping writes key/value lines; keys are already YAML scalars.
+func yamlMapping(keys []string, vals []reflect.Value, path yamlPath) (string, []string, bool) {
+	var lines []string
+	for i, key := range keys {
+		scalar, block, ok := yamlNode(vals[i], path)
+		if !ok {
+			return "", nil, false
+		}
+		if block == nil {
+			lines = append(lines, key+": "+scalar)
+			continue
+		}
+		lines = append(lines, key+":")
+		for _, line := range block {
+			lines = append(lines, "  "+line)
+		}
+	}
+	return "", lines, true
+}
+
+func yamlSequence(rv reflect.Value, path yamlPath) (string, []string, bool) {
+	if rv.Len() == 0 {
+		return "[]", nil, true
+	}
+	var lines []string
+	for i := 0; i < rv.Len(); i++ {
+		scalar, block, ok := yamlNode(rv.Index(i), path)
+		if !ok {
+			return "", nil, false
+		}
+		if block == nil {
+			lines = append(lines, "- "+scalar)
+			continue
+		}
+		lines = append(lines, "- "+block[0])
+		for _, line := range block[1:] {
+			lines = append(lines, "  "+line)
+		}
+	}
+	return "", lines, true
+}
+
+func yamlFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return ".nan"
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+// yamlString quotes s when a YAML parser would otherwise read it as
+// something other than the same plain string.
+func yamlString(s string) string {
+	if yamlNeedsQuotes(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func yamlNeedsQuotes(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "~", "null", "true", "false", "yes", "no", "on", "off":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return true
+	}
+	return strings.IndexFunc(s, isControl) >= 0
+}
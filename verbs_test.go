@@ -0,0 +1,646 @@
+package fstr_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crazywolf132/fstr"
+)
+
+func TestVerbs(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{
+			name:   "Debug_escapes_control_characters",
+			format: "{:?}",
+			args:   []interface{}{Person{Name: "line1\nline2", Email: "a\tb", Age: 3}},
+			want:   `{Name:line1\nline2 Email:a\tb Age:3 Detail:<nil>}`,
+		},
+		{
+			name:   "Debug_escapes_inside_map",
+			format: "{:?}",
+			args:   []interface{}{map[string]string{"k": "x\r\x01"}},
+			want:   `map[k:x\r\x01]`,
+		},
+		{
+			name:   "Plain_placeholder_keeps_control_characters",
+			format: "{}",
+			args:   []interface{}{"line1\nline2"},
+			want:   "line1\nline2",
+		},
+		{
+			name:   "YAML_scalar",
+			format: "{:yaml}",
+			args:   []interface{}{42},
+			want:   "42",
+		},
+		{
+			name:   "YAML_nested_map_sorted_keys",
+			format: "{:yaml}",
+			args: []interface{}{
+				map[string]interface{}{
+					"name":  "api",
+					"ports": []int{80, 443},
+					"db": map[string]interface{}{
+						"port": 5432,
+						"host": "localhost",
+					},
+				},
+			},
+			want: "db:\n  host: localhost\n  port: 5432\nname: api\nports:\n  - 80\n  - 443",
+		},
+		{
+			name:   "YAML_list_of_maps",
+			format: "{:yaml}",
+			args: []interface{}{
+				[]map[string]interface{}{
+					{"id": 1, "tags": []string{"a", "b"}},
+					{"id": 2, "tags": []string{}},
+				},
+			},
+			want: "- id: 1\n  tags:\n    - a\n    - b\n- id: 2\n  tags: []",
+		},
+		{
+			name:   "YAML_quotes_ambiguous_strings",
+			format: "{:yaml}",
+			args: []interface{}{
+				map[string]string{"a": "true", "b": "123", "c": "x: y", "d": "", "e": "plain"},
+			},
+			want: "a: \"true\"\nb: \"123\"\nc: \"x: y\"\nd: \"\"\ne: plain",
+		},
+		{
+			name:   "YAML_struct_in_declaration_order",
+			format: "{:yaml}",
+			args:   []interface{}{Person{Name: "Ann", Age: 30}},
+			want:   "Name: Ann\nEmail: \"\"\nAge: 30\nDetail: null",
+		},
+		{
+			name:   "YAML_keys_that_print_alike",
+			format: "{:yaml}",
+			args:   []interface{}{map[interface{}]string{1: "int", "1": "string", true: "bool"}},
+			want:   "\"1\": string\n1: int\ntrue: bool",
+		},
+		{
+			name:   "YAML_int_keys_unquoted",
+			format: "{:yaml}",
+			args:   []interface{}{map[int]string{10: "b", 2: "a"}},
+			want:   "10: b\n2: a",
+		},
+		{
+			name:   "YAML_self_reference",
+			format: "{:yaml}",
+			args:   []interface{}{selfRef()},
+			want:   "<invalid yaml>",
+		},
+		{
+			name:   "YAML_map_containing_itself",
+			format: "{:yaml}",
+			args:   []interface{}{selfMap()},
+			want:   "<invalid yaml>",
+		},
+		{
+			name:   "YAML_shared_pointer_is_not_a_cycle",
+			format: "{:yaml}",
+			args:   []interface{}{sharedLeaves()},
+			want:   "A:\n  N: 1\n  Next: null\nB:\n  N: 1\n  Next: null",
+		},
+		{
+			name:   "YAML_unrepresentable_value",
+			format: "{:yaml}",
+			args:   []interface{}{map[string]interface{}{"ch": make(chan int)}},
+			want:   "<invalid yaml>",
+		},
+		{
+			name:   "CSV_plain_fields",
+			format: "{:csv}",
+			args:   []interface{}{[]interface{}{"a", 1, true}},
+			want:   "a,1,true",
+		},
+		{
+			name:   "CSV_field_with_comma",
+			format: "{:csv}",
+			args:   []interface{}{[]string{"a,b", "c"}},
+			want:   `"a,b",c`,
+		},
+		{
+			name:   "CSV_field_with_quotes",
+			format: "{:csv}",
+			args:   []interface{}{[]string{`say "hi"`, "ok"}},
+			want:   `"say ""hi""",ok`,
+		},
+		{
+			name:   "CSV_field_with_newline",
+			format: "{:csv}",
+			args:   []interface{}{[]string{"line1\nline2", ""}},
+			want:   "\"line1\nline2\",",
+		},
+		{
+			name:   "CSV_not_a_slice",
+			format: "{:csv}",
+			args:   []interface{}{"abc"},
+			want:   "<invalid csv>",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := fstr.Sprintf(tc.format, tc.args...)
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// opError wraps an error without repeating its message, like *os.PathError.
+type opError struct {
+	op  string
+	err error
+}
+
+func (e *opError) Error() string { return e.op }
+func (e *opError) Unwrap() error { return e.err }
+
+// joinedError mirrors errors.Join, which needs Go 1.20: its message is the
+// messages of its errors, one per line.
+type joinedError []error
+
+func joinErrors(errs ...error) error { return joinedError(errs) }
+
+func (e joinedError) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (e joinedError) Unwrap() []error { return e }
+
+func TestErrChain(t *testing.T) {
+	inner := errors.New("inner")
+	middle := fmt.Errorf("middle: %w", inner)
+
+	tests := []struct {
+		name string
+		val  interface{}
+		want string
+	}{
+		{"Two_level_wrap", fmt.Errorf("outer: %w", middle), "outer: middle: inner"},
+		{"Wrapper_hiding_cause", &opError{op: "outer", err: middle}, "outer: middle: inner"},
+		{"Transparent_wrapper", fmt.Errorf("%w", inner), "inner"},
+		{"Joined", joinErrors(errors.New("a"), &opError{op: "b", err: inner}), "a; b: inner"},
+		{"Wrapped_join", &opError{op: "batch", err: joinErrors(errors.New("x"), errors.New("y"))}, "batch: x; y"},
+		{"Plain_error", inner, "inner"},
+		{"Not_an_error", 42, "42"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf("{:errchain}", tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGoDirectivePassthrough(t *testing.T) {
+	p := Person{Name: "Ivy", Email: "ivy@example.com", Age: 7}
+	tests := []struct {
+		name   string
+		format string
+		arg    interface{}
+		want   string
+	}{
+		{"Go_syntax_struct", "{:%#v}", p, fmt.Sprintf("%#v", p)},
+		{"Quoted_string", "{:%q}", "a\"b", `"a\"b"`},
+		{"Flags_and_width", "[{:%-6d}]", 42, "[42    ]"},
+		{"Named_field", "{Name:%q}", p, `"Ivy"`},
+		{"Nil_slice_is_not_normalized", "{:%#v}", []int(nil), "[]int(nil)"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.arg); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestGoDirectiveMatchesFmt checks that "{:%...}" is a drop-in for the same
+// fmt directive, so fmt.Sprintf calls can be migrated one verb at a time.
+func TestGoDirectiveMatchesFmt(t *testing.T) {
+	directives := []string{"%05d", "%+d", "%x", "%#o", "%8.3f", "%-8.3f|", "%e", "%q", "%6s", "%-6s|", "%v", "%T", "%t", "%c", "%U", "%08b", "%.2s"}
+	values := []interface{}{42, -7, 3.14159, "go", true, 'x', uint8(200), []int{1, 2}}
+
+	for _, directive := range directives {
+		for _, val := range values {
+			want := fmt.Sprintf(directive, val)
+			if got := fstr.Sprintf("{:"+directive+"}", val); got != want {
+				t.Errorf("{:%s} on %#v: got %q, want %q", directive, val, got, want)
+			}
+		}
+	}
+
+	if got, want := fstr.Sprintf("id={:%05d} name={:%-4s}|", 42, "ab"), fmt.Sprintf("id=%05d name=%-4s|", 42, "ab"); got != want {
+		t.Errorf("mixed: got %q, want %q", got, want)
+	}
+}
+
+func TestBooleans(t *testing.T) {
+	type Enabled bool
+
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Default", "{}", []interface{}{true}, "true"},
+		{"Right_aligned", "[{:>6}]", []interface{}{true}, "[  true]"},
+		{"Centered", "[{:*^7}]", []interface{}{false}, "[*false*]"},
+		{"Default_aligns_left", "[{:6t}]", []interface{}{true}, "[true  ]"},
+		{"Yes_no", "{:y}/{:y}", []interface{}{true, false}, "yes/no"},
+		{"Upper_yes_padded", "[{:>5Y}]", []interface{}{true}, "[  YES]"},
+		{"Upper_no_padded", "[{:-<4Y}]", []interface{}{false}, "[NO--]"},
+		{"Named_bool_type", "{:Y}", []interface{}{Enabled(true)}, "YES"},
+		{"Non_bool", "{:Y}", []interface{}{3}, "3"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProjection(t *testing.T) {
+	people := []Person{
+		{Name: "Alice", Age: 30, Detail: &Detail{City: "Oslo"}},
+		{Name: "Bob", Age: 25},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		arg    interface{}
+		want   string
+	}{
+		{"Field", "{:.Name}", people, "[Alice, Bob]"},
+		{"Nested_field", "{:.Detail.City}", people[:1], "[Oslo]"},
+		{"Pointer_elements", "{:.Age}", []*Person{&people[0], &people[1]}, "[30, 25]"},
+		{"Array", "{:.Name}", [1]Person{people[1]}, "[Bob]"},
+		{"Maps", "{:.id}", []map[string]int{{"id": 1}, {"id": 2}}, "[1, 2]"},
+		{"Non_struct_elements", "{:.Name}", []int{1, 2}, "[1, 2]"},
+		{"Empty_slice", "{:.Name}", []Person{}, "[]"},
+		{"Named_field", "{Members:.Name}", Team{Members: people}, "[Alice, Bob]"},
+		{"Not_a_slice", "{:.Name}", 42, "42"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.arg); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestKeysProjection(t *testing.T) {
+	user := map[string]interface{}{"name": "Alice", "age": 30, "city": "Oslo", "tags": []string{"a"}}
+	person := Person{Name: "Bob", Age: 25, Detail: &Detail{City: "Rome"}}
+
+	tests := []struct {
+		name   string
+		format string
+		arg    interface{}
+		want   string
+	}{
+		{"Subset_in_order", "{:keys(name,age)}", user, "name: Alice, age: 30"},
+		{"Order_from_spec", "{:keys(age,name)}", user, "age: 30, name: Alice"},
+		{"Absent_key_skipped", "{:keys(name,zip,city)}", user, "name: Alice, city: Oslo"},
+		{"Only_absent", "[{:keys(zip)}]", user, "[]"},
+		{"Spaces_trimmed", "{:keys( name , tags )}", user, "name: Alice, tags: [a]"},
+		{"Struct_fields", "{:keys(Name,Detail.City)}", person, "Name: Bob, Detail.City: Rome"},
+		{"Struct_pointer", "{:keys(Age)}", &person, "Age: 25"},
+		{"Padded", "[{:>14keys(age)}]", user, "[       age: 30]"},
+		{"Named_arg", "{user:keys(name)}", fstr.Args{"user": user}, "name: Alice"},
+		{"Not_a_map", "{:keys(name)}", 42, "42"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.arg); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValueTemplate(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		arg    interface{}
+		want   string
+	}{
+		{"Hex_and_binary", "{0:<%#x (%b)>}", 255, "0xff (11111111)"},
+		{"Auto_placeholder", "{:<%d = %#o>}", 8, "8 = 010"},
+		{"Literal_percent", "{:<%d%% of %d>}", 50, "50% of 50"},
+		{"Inner_angle_brackets", "{:<<%s>>}", "tag", "<tag>"},
+		{"Explicit_index", "{:<%[1]x/%[1]X>}", 171, "ab/AB"},
+		{"Named_field", "{Age:<%d (%x)>}", Person{Age: 42}, "42 (2a)"},
+		{"Fill_and_align_unaffected", "[{:<>5}]", "ab", "[<<<ab]"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.arg); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+type fakeFrame string
+
+func (f fakeFrame) Format(s fmt.State, verb rune) {
+	if s.Flag('+') {
+		fmt.Fprintf(s, "%s\n\t%s.go:1", string(f), string(f))
+		return
+	}
+	fmt.Fprint(s, string(f))
+}
+
+type stackError struct {
+	msg    string
+	frames []fakeFrame
+}
+
+func (e *stackError) Error() string              { return e.msg }
+func (e *stackError) StackTrace() []fakeFrame    { return e.frames }
+func (e *stackError) Format(s fmt.State, _ rune) { fmt.Fprintf(s, "%s (with stack)", e.msg) }
+
+func TestErrStack(t *testing.T) {
+	inner := &stackError{msg: "disk full", frames: []fakeFrame{"write", "main"}}
+	wrapped := fmt.Errorf("save failed: %w", inner)
+
+	tests := []struct {
+		name   string
+		format string
+		arg    interface{}
+		want   string
+	}{
+		{"Stack_frames", "{:errstack}", inner, "disk full\nwrite\n\twrite.go:1\nmain\n\tmain.go:1"},
+		{"Stack_from_wrapped_error", "{:errstack}", wrapped, wrapped.Error() + "\nwrite\n\twrite.go:1\nmain\n\tmain.go:1"},
+		{"Plain_error", "{:errstack}", errors.New("boom"), "boom"},
+		{"Non_error", "{:errstack}", 7, "7"},
+		{"Plus_v_uses_fmt", "{:+v}", inner, "disk full (with stack)"},
+		{"Plus_v_struct", "{:+v}", Detail{City: "Oslo"}, "{City:Oslo Data:map[]}"},
+		{"Plain_v", "{:v}", 3, "3"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.arg); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTextLayout(t *testing.T) {
+	paragraph := "The quick brown fox jumps over the lazy dog and keeps running."
+
+	tests := []struct {
+		name   string
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"Wrap_paragraph", "{:wrap16}", []interface{}{paragraph},
+			"The quick brown\nfox jumps over\nthe lazy dog and\nkeeps running."},
+		{"Wrap_keeps_newlines", "{:wrap10}", []interface{}{"one two three\n\nfour five"},
+			"one two\nthree\n\nfour five"},
+		{"Wrap_long_word", "{:wrap5}", []interface{}{"a extraordinary b"}, "a\nextraordinary\nb"},
+		{"Wrap_collapses_spaces", "{:wrap20}", []interface{}{"a   b\tc"}, "a b c"},
+		{"Wrap_default_width", "{:wrap}", []interface{}{paragraph}, paragraph},
+		{"Wrap_runes", "{:wrap5}", []interface{}{"héé ñö ü"}, "héé\nñö ü"},
+		{"Wrap_non_string", "{:wrap3}", []interface{}{[]int{1, 2, 3}}, "[1\n2\n3]"},
+		{"Indent", "{:indent2}", []interface{}{"a\nb"}, "  a\n  b"},
+		{"Indent_skips_blank_lines", "{:indent2}", []interface{}{"a\n\nb\n"}, "  a\n\n  b\n"},
+		{"Indent_default", "{:indent}", []interface{}{"x"}, "    x"},
+		{"Indent_zero", "{:indent0}", []interface{}{"x"}, "x"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.args...); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		want string
+	}{
+		{"Words", "hello wide world", "Hello Wide World"},
+		{"Lowercases_the_rest", "hELLO WORLD", "Hello World"},
+		{"Irish_name", "o'brien", "O'Brien"},
+		{"Italian_name", "d'angelo", "D'Angelo"},
+		{"Contraction", "don't stop", "Don't Stop"},
+		{"Short_contraction", "i'm here", "I'm Here"},
+		{"Curly_apostrophe", "o’neil isn’t", "O’Neil Isn’t"},
+		{"Hyphen", "jean-luc picard", "Jean-Luc Picard"},
+		{"Accented", "élan ÉCOLE ñandú", "Élan École Ñandú"},
+		{"Combining_accent", "e\u0301cole e\u0301te\u0301", "E\u0301cole E\u0301te\u0301"},
+		{"Digits", "3rd place", "3rd Place"},
+		{"Digraph", "ǆungla", "ǅungla"},
+		{"Punctuation", "(hello),world", "(Hello),World"},
+		{"Non_string", 42, "42"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf("{:title}", tc.in); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompact(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"Below_thousand", "{:compact}", 999, "999"},
+		{"Thousand", "{:compact}", 1000, "1k"},
+		{"Thousands", "{:compact}", 12345, "12.3k"},
+		{"Rounds_up_to_million", "{:compact}", 999999, "1M"},
+		{"Million", "{:compact}", 1000000, "1M"},
+		{"Millions", "{:compact}", 1200000, "1.2M"},
+		{"Billions", "{:compact}", int64(3400000000), "3.4B"},
+		{"Trillions", "{:compact}", uint64(5e15), "5000T"},
+		{"Negative", "{:compact}", -12345, "-12.3k"},
+		{"Zero", "{:compact}", 0, "0"},
+		{"Small_float", "{:compact}", 12.345, "12.3"},
+		{"Precision", "{:.2compact}", 12345, "12.35k"},
+		{"Precision_keeps_zeros", "{:.2compact}", 1000, "1.00k"},
+		{"Precision_zero", "{:.0compact}", 1500, "2k"},
+		{"Precision_rounds_up", "{:.2compact}", 999999, "1.00M"},
+		{"Padded", "[{:>6compact}]", 12345, "[ 12.3k]"},
+		{"Non_number", "{:compact}", "n/a", "n/a"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+type ServerConfig struct {
+	Name     string
+	Port     int
+	TLS      *TLSConfig
+	Labels   map[string]string
+	Tags     []string
+	Timeout  time.Duration
+	Fallback *TLSConfig
+	Empty    struct{}
+}
+
+type TLSConfig struct {
+	Enabled bool
+	Cert    string
+}
+
+func TestKVLines(t *testing.T) {
+	cfg := ServerConfig{
+		Name:    "api\nprimary",
+		Port:    8080,
+		TLS:     &TLSConfig{Enabled: true, Cert: "/etc/cert.pem"},
+		Labels:  map[string]string{"zone": "eu-1", "env": "prod"},
+		Tags:    []string{"a", "b"},
+		Timeout: 1500 * time.Millisecond,
+	}
+
+	const golden = `Empty: {}
+Fallback: <nil>
+Labels:
+  env: prod
+  zone: eu-1
+Name: api\nprimary
+Port: 8080
+TLS:
+  Cert: /etc/cert.pem
+  Enabled: true
+Tags: [a b]
+Timeout: 1.5s`
+
+	if got := fstr.Sprintf("{:kvlines}", cfg); got != golden {
+		t.Errorf("got:\n%s\nwant:\n%s", got, golden)
+	}
+	if got := fstr.Sprintf("{:kvlines}", &cfg); got != golden {
+		t.Errorf("pointer got:\n%s\nwant:\n%s", got, golden)
+	}
+	if got, want := fstr.Sprintf("{:kvlines}", 42), "42"; got != want {
+		t.Errorf("scalar: got %q, want %q", got, want)
+	}
+
+	const declared = `Name: api\nprimary
+Port: 8080
+TLS:
+  Enabled: true
+  Cert: /etc/cert.pem
+Labels:
+  env: prod
+  zone: eu-1
+Tags: [a b]
+Timeout: 1.5s
+Fallback: <nil>
+Empty: {}`
+
+	if got := fstr.Sprintf("{:#kvlines}", cfg); got != declared {
+		t.Errorf("declaration order got:\n%s\nwant:\n%s", got, declared)
+	}
+
+	type node struct {
+		Name string
+		Next *node
+	}
+	loop := &node{Name: "a"}
+	loop.Next = loop
+	if got := fstr.Sprintf("{:kvlines}", loop); !strings.HasPrefix(got, "Name: a\nNext:\n  Name: a\n") {
+		t.Errorf("cycle: got %q", got)
+	}
+}
+
+func TestVerbWidthAndPrecision(t *testing.T) {
+	err := fmt.Errorf("load config: %w", errors.New("no such file"))
+	tests := []struct {
+		name   string
+		format string
+		val    interface{}
+		want   string
+	}{
+		{"Title_right", "[{:>14title}]", "hello world", "[   Hello World]"},
+		{"Title_center_fill", "[{:*^15title}]", "hello world", "[**Hello World**]"},
+		{"Title_precision", "[{:.5title}]", "hello world", "[Hello]"},
+		{"Title_precision_and_width", "[{:>8.5title}]", "hello world", "[   Hello]"},
+		{"Yes_no_padded", "[{:<5y}]", true, "[yes  ]"},
+		{"Yes_no_precision", "[{:.1Y}]", false, "[N]"},
+		{"Errchain_precision", "[{:.11errchain}]", err, "[load config]"},
+		{"Multibyte_precision", "[{:.3title}]", "élan vital", "[Éla]"},
+		{"Yaml_keeps_whole", "{:.2yaml}", map[string]int{"a": 1}, "a: 1"},
+		{"Debug_keeps_whole", "{:.2?}", struct{ A int }{1}, "{A:1}"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fstr.Sprintf(tc.format, tc.val); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+type yamlNode struct {
+	N    int
+	Next *yamlNode
+}
+
+func selfRef() *yamlNode {
+	n := &yamlNode{N: 1}
+	n.Next = n
+	return n
+}
+
+func selfMap() map[string]interface{} {
+	m := map[string]interface{}{"a": 1}
+	m["self"] = m
+	return m
+}
+
+func sharedLeaves() interface{} {
+	leaf := &yamlNode{N: 1}
+	return struct{ A, B *yamlNode }{leaf, leaf}
+}